@@ -0,0 +1,3 @@
+module github.com/MinhNHHH/nand2tetris
+
+go 1.21
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MinhNHHH/nand2tetris/projects/06/hackasm"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: disassembler <file.hack>")
+		os.Exit(2)
+	}
+	inputFile := os.Args[1]
+
+	input, err := os.Open(inputFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	defer input.Close()
+
+	var output bytes.Buffer
+	diagnostics, err := hackasm.Disassemble(input, &output, inputFile)
+	for _, diagnostic := range diagnostics {
+		fmt.Fprintln(os.Stderr, diagnostic)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+
+	// Invalid words are kept as comments, so the listing is still written
+	// for inspection, but the exit status reports them.
+	outputFile := strings.TrimSuffix(inputFile, filepath.Ext(inputFile)) + ".dis.asm"
+	if err := os.WriteFile(outputFile, output.Bytes(), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	if hackasm.HasErrors(diagnostics) {
+		os.Exit(1)
+	}
+}
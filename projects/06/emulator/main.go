@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/MinhNHHH/nand2tetris/projects/06/hackcpu"
+	"github.com/MinhNHHH/nand2tetris/projects/06/tstscript"
+)
+
+func main() {
+	cycles := flag.Int("cycles", 1000000, "stop after this many instructions if the program has not halted")
+	ramImage := flag.String("ram", "", "load this RAM image (as written by the assembler's -ram) before running")
+	key := flag.Int("key", 0, "keep this key code pressed in the KBD register")
+	dump := flag.String("dump", "0-15", "RAM ranges to print after the run, e.g. 0-15,256-260")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: emulator [flags] <file.hack>")
+		fmt.Fprintln(os.Stderr, "       emulator <script.tst>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if filepath.Ext(flag.Arg(0)) == ".tst" {
+		runScript(flag.Arg(0))
+		return
+	}
+	ranges, err := parseRanges(*dump)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(2)
+	}
+
+	computer := hackcpu.New()
+	if err := load(flag.Arg(0), computer.LoadROM); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	if *ramImage != "" {
+		if err := load(*ramImage, computer.LoadRAM); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+	}
+	computer.SetKey(uint16(*key))
+
+	halted, err := computer.Run(*cycles)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	if halted {
+		fmt.Printf("halted after %d cycles\n", computer.Cycles)
+	} else {
+		fmt.Printf("stopped after %d cycles without halting\n", computer.Cycles)
+	}
+	fmt.Printf("A=%d D=%d PC=%d\n", computer.A, int16(computer.D), computer.PC)
+	for _, r := range ranges {
+		for address := r[0]; address <= r[1]; address++ {
+			fmt.Printf("RAM[%d]=%d\n", address, int16(computer.RAM[address]))
+		}
+	}
+}
+
+// runScript runs a course test script and compares its output, exiting
+// non-zero on a comparison failure.
+func runScript(fileName string) {
+	result, err := tstscript.RunFile(fileName, tstscript.Options{Echo: os.Stdout})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	if result.Mismatch != nil {
+		fmt.Fprintln(os.Stderr, result.Mismatch)
+		os.Exit(1)
+	}
+	if result.CompareFile != "" {
+		fmt.Println("End of script - Comparison ended successfully")
+	} else {
+		fmt.Println("End of script")
+	}
+}
+
+func load(fileName string, loader func(r io.Reader) error) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := loader(file); err != nil {
+		return fmt.Errorf("%s: %v", fileName, err)
+	}
+	return nil
+}
+
+// parseRanges reads a comma-separated list of addresses and first-last
+// address ranges.
+func parseRanges(text string) ([][2]int, error) {
+	ranges := [][2]int{}
+	for _, part := range strings.Split(text, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		if !isRange {
+			last = first
+		}
+		start, err1 := strconv.Atoi(first)
+		end, err2 := strconv.Atoi(last)
+		if err1 != nil || err2 != nil || start < 0 || end < start || end >= hackcpu.RAMSize {
+			return nil, fmt.Errorf("bad RAM range %q", part)
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges, nil
+}
@@ -0,0 +1,468 @@
+// Package hackasm translates Hack assembly into Hack machine code.
+package hackasm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/MinhNHHH/nand2tetris/projects/06/hackisa"
+)
+
+// Options controls a single call to Assemble.
+type Options struct {
+	// FileName labels diagnostics and the source map.
+	FileName string
+	// Listing, when set, receives a listing with the ROM address, hex and
+	// binary encoding next to every source line.
+	Listing io.Writer
+	// Symbols, when set, receives the symbol table and source map as JSON.
+	Symbols io.Writer
+	// RAM, when set, receives the RAM initialization image built from
+	// .data, .string and .reserve directives.
+	RAM io.Writer
+	// Optimize runs the peephole optimizer on the expanded source before
+	// addresses are assigned.
+	Optimize bool
+	// Stats, when set, is filled with figures about the assembled program.
+	Stats *Stats
+	// Memory sets the ROM and RAM limits to check; the zero value checks
+	// against StandardMemory.
+	Memory MemoryMap
+	// Encoder picks the output file format; nil writes a .hack file.
+	Encoder Encoder
+	// Lint adds warnings for suspicious but valid code.
+	Lint bool
+	// Strict turns off implicit variables: every symbol must be a label,
+	// data block or variable declared with .var.
+	Strict bool
+	// Case says how symbols that differ only in letter case are treated.
+	Case CaseMode
+	// Open reads files named by .include directives. Relative names are
+	// resolved against the directory of the including file. Includes are
+	// rejected when Open is nil.
+	Open func(name string) (io.ReadCloser, error)
+}
+
+// Source is one named input to AssembleSources.
+type Source struct {
+	Name   string
+	Reader io.Reader
+}
+
+type translateInstruction struct {
+	parser         *parser
+	options        Options
+	currentAddress int
+	currentROM     int
+	words          []uint16
+	listing        bytes.Buffer
+	// variableAddresses holds the addresses of implicit variables when
+	// they were already allocated by an earlier pass.
+	variableAddresses map[string]int
+	// listedFile and listedRow are the source line of the last listing
+	// row, so the rows it expanded to are marked as continuations.
+	listedFile  string
+	listedRow   int
+	symbolTable map[string]int
+	labels      map[string]SourceLocation
+	variables   map[string]SourceLocation
+	// fixedVariables are the variables declared with .var at an address
+	// of their own rather than allocated.
+	fixedVariables map[string]bool
+	declared       map[string]bool
+	spellings      map[string]string
+	data           []dataBlock
+	sourceMap      []SourceLocation
+	diagnostics    []Diagnostic
+}
+
+func newTranslateInstruction(parser *parser, options Options) *translateInstruction {
+	options.Memory = options.Memory.withDefaults()
+	return &translateInstruction{
+		parser:         parser,
+		options:        options,
+		currentAddress: options.Memory.VariableBase,
+		symbolTable:    newSymbolTable(),
+		labels:         map[string]SourceLocation{},
+		variables:      map[string]SourceLocation{},
+		fixedVariables: map[string]bool{},
+		declared:       map[string]bool{},
+		spellings:      predefinedSpellings(),
+	}
+}
+
+// Assemble reads Hack assembly from r and writes the ROM image to w, by
+// default as one 16-bit binary word per line. Problems in the source are
+// returned as diagnostics; the error is reserved for I/O failures. Nothing
+// is written to w when any diagnostic is an error.
+func Assemble(r io.Reader, w io.Writer, options Options) ([]Diagnostic, error) {
+	return AssembleSources([]Source{{Name: options.FileName, Reader: r}}, w, options)
+}
+
+// AssembleSources assembles several files into one ROM image, placing
+// their code in the given order. Labels are global across files except
+// those starting with '.', which are local to the file defining them.
+func AssembleSources(sources []Source, w io.Writer, options Options) ([]Diagnostic, error) {
+	if options.FileName == "" && len(sources) > 0 {
+		options.FileName = sources[0].Name
+	}
+	// Expand includes, macros and pseudo-instructions before the first pass
+	// so ROM addresses are counted on the final instruction stream.
+	preprocessor := newPreprocessor(options)
+	lines := []sourceLine{}
+	for _, source := range sources {
+		sourceLines, err := readLines(source.Reader, source.Name)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, preprocessor.expandFile(source.Name, sourceLines)...)
+	}
+	lines = localizeLabels(lines)
+
+	// The source is always checked as written, so the optimizer cannot
+	// drop a line that is in error. The ROM limit applies to the program
+	// actually emitted, which may be the optimized one.
+	options.Memory = options.Memory.withDefaults()
+	checkOptions := options
+	if options.Optimize {
+		checkOptions.Memory.ROMSize = math.MaxInt
+	}
+	translateInstruction := newTranslateInstruction(newParser(lines), checkOptions)
+	translateInstruction.diagnostics = preprocessor.diagnostics
+	// Frist pass: build a symbolTable
+	translateInstruction.buildSymbolTable()
+
+	// Second pass: Translate instruction to binary
+	translateInstruction.genCode()
+	if options.Lint && !HasErrors(translateInstruction.diagnostics) {
+		translateInstruction.lint()
+	}
+	if options.Optimize && !HasErrors(translateInstruction.diagnostics) {
+		translateInstruction = reassemble(translateInstruction, lines, options)
+	}
+	sortDiagnostics(translateInstruction.diagnostics)
+	if HasErrors(translateInstruction.diagnostics) {
+		return translateInstruction.diagnostics, nil
+	}
+	encoder := options.Encoder
+	if encoder == nil {
+		encoder = HackEncoder{}
+	}
+	if err := encoder.Encode(w, translateInstruction.words); err != nil {
+		return translateInstruction.diagnostics, err
+	}
+	if options.Listing != nil {
+		if _, err := translateInstruction.listing.WriteTo(options.Listing); err != nil {
+			return translateInstruction.diagnostics, err
+		}
+	}
+	if options.Symbols != nil {
+		if err := writeSymbolMap(options.Symbols, translateInstruction.symbolMap()); err != nil {
+			return translateInstruction.diagnostics, err
+		}
+	}
+	var err error
+	if options.RAM != nil {
+		err = writeRAMImage(options.RAM, translateInstruction.data)
+	}
+	return translateInstruction.diagnostics, err
+}
+
+// reassemble assembles the optimized form of lines, which checked has
+// already assembled without errors. Variables keep the addresses checked
+// gave them in source order, even those whose first use was optimized
+// away. The diagnostics are those of checked plus any ROM overflow.
+func reassemble(checked *translateInstruction, lines []sourceLine, options Options) *translateInstruction {
+	optimized, saved := optimize(lines)
+	if options.Stats != nil {
+		options.Stats.OptimizedWords = saved
+	}
+	t := newTranslateInstruction(newParser(optimized), options)
+	t.buildSymbolTable()
+	t.variableAddresses = map[string]int{}
+	for symbol := range checked.variables {
+		t.variableAddresses[symbol] = checked.symbolTable[symbol]
+	}
+	t.currentAddress = checked.currentAddress
+	t.genCode()
+
+	diagnostics := checked.diagnostics
+	for _, diagnostic := range t.diagnostics {
+		if diagnostic.Severity == SeverityError {
+			diagnostics = append(diagnostics, diagnostic)
+		}
+	}
+	t.diagnostics = diagnostics
+	return t
+}
+
+// report records an error at the given 0-based offset into the current
+// command.
+func (t *translateInstruction) report(code Code, offset int, format string, args ...interface{}) {
+	t.diagnose(SeverityError, code, offset, format, args...)
+}
+
+// warn records a warning, which does not stop the output being written.
+func (t *translateInstruction) warn(code Code, offset int, format string, args ...interface{}) {
+	t.diagnose(SeverityWarning, code, offset, format, args...)
+}
+
+// warnAt records a warning at a remembered location rather than at the
+// current command.
+func (t *translateInstruction) warnAt(location SourceLocation, code Code, format string, args ...interface{}) {
+	t.diagnostics = append(t.diagnostics, Diagnostic{
+		File:     location.File,
+		Line:     location.Line,
+		Column:   1,
+		Severity: SeverityWarning,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (t *translateInstruction) diagnose(severity Severity, code Code, offset int, format string, args ...interface{}) {
+	t.diagnostics = append(t.diagnostics, Diagnostic{
+		File:     t.parser.File(),
+		Line:     t.parser.Line(),
+		Column:   t.parser.Column() + offset,
+		Severity: severity,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (t *translateInstruction) buildSymbolTable() {
+	t.parser.Reset()
+	for t.parser.HasMoreCommands() {
+		t.parser.Advance()
+		if t.parser.CommandType() == lCommand {
+			if !strings.HasSuffix(t.parser.Command(), ")") {
+				t.report(ErrMalformedLabel, len(t.parser.Command()), "missing ')' after label %q", t.parser.Symbol())
+				continue
+			}
+			symbol := strings.TrimSpace(t.parser.Symbol())
+			if symbol == "" {
+				t.report(ErrEmptySymbol, 1, "empty label name")
+				continue
+			}
+			symbol, ok := t.symbolName(symbol, 1)
+			if !ok {
+				continue
+			}
+			if previous, exist := t.definition(symbol); exist {
+				t.report(ErrDuplicateLabel, 1, "label %q already defined at %s:%d", symbol, previous.File, previous.Line)
+				continue
+			}
+			t.labels[symbol] = t.location()
+			t.symbolTable[symbol] = t.currentROM
+		} else if t.parser.CommandType() == directiveCommand {
+			t.defineDirective()
+		} else if t.parser.CommandType() == cCommand || t.parser.CommandType() == aCommand {
+			if t.currentROM == t.options.Memory.ROMSize {
+				t.report(ErrROMOverflow, 0, "program does not fit in ROM: ROM[%d] is past the %d-word limit", t.currentROM, t.options.Memory.ROMSize)
+			}
+			t.currentROM++
+		}
+	}
+}
+
+// isConstant reports whether an A-instruction operand is meant as a number.
+// Symbols may not start with a digit, so anything that does is a constant.
+func isConstant(symbol string) bool {
+	return symbol[0] >= '0' && symbol[0] <= '9' || symbol[0] == '-' || symbol[0] == '+'
+}
+
+// parseConstant accepts decimal, 0x-prefixed hex and 0b-prefixed binary
+// literals in the range 0..limit. offset places diagnostics in the command.
+func (t *translateInstruction) parseConstant(symbol string, offset int, limit int) (int, bool) {
+	digits, base := symbol, 10
+	lower := strings.ToLower(symbol)
+	if strings.HasPrefix(lower, "0x") {
+		digits, base = symbol[2:], 16
+	} else if strings.HasPrefix(lower, "0b") {
+		digits, base = symbol[2:], 2
+	}
+	if base != 10 && strings.ContainsAny(digits, "+-") {
+		t.report(ErrMalformedConstant, offset, "malformed constant %q", symbol)
+		return 0, false
+	}
+
+	value, err := strconv.ParseInt(digits, base, 64)
+	if err != nil {
+		if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+			t.report(ErrConstantOutOfRange, offset, "constant %s out of range 0..%d", symbol, limit)
+		} else {
+			t.report(ErrMalformedConstant, offset, "malformed constant %q", symbol)
+		}
+		return 0, false
+	}
+	if value < 0 || value > int64(limit) {
+		t.report(ErrConstantOutOfRange, offset, "constant %s out of range 0..%d", symbol, limit)
+		return 0, false
+	}
+	return int(value), true
+}
+
+func (t *translateInstruction) genCode() {
+	t.parser.Reset()
+	t.currentROM = 0
+	t.writeListingHeader()
+	for t.parser.HasMoreCommands() {
+		t.parser.Advance()
+		var word uint16
+		note := ""
+		if t.parser.CommandType() == aCommand {
+			symbol := t.parser.Symbol()
+			if symbol == "" {
+				t.report(ErrEmptySymbol, 1, "missing value after '@'")
+				continue
+			}
+			if isConstant(symbol) {
+				value, ok := t.parseConstant(symbol, 1, hackisa.MaxAddress)
+				if !ok {
+					continue
+				}
+				word = uint16(value)
+			} else {
+				symbol, ok := t.symbolName(symbol, 1)
+				if !ok {
+					continue
+				}
+				if value, exist := t.symbolTable[symbol]; exist {
+					word = uint16(value)
+				} else if t.options.Strict {
+					t.reportUndeclared(symbol)
+					continue
+				} else {
+					address, allocated := t.variableAddresses[symbol]
+					if !allocated {
+						address = t.allocateRAM(symbol, 1)
+					}
+					t.symbolTable[symbol] = address
+					t.variables[symbol] = t.location()
+					word = uint16(t.symbolTable[symbol])
+				}
+				note = t.describeSymbol(symbol)
+			}
+		} else if t.parser.CommandType() == cCommand {
+			encoded, ok := t.encodeCCommand()
+			if !ok {
+				continue
+			}
+			word = encoded
+		} else {
+			if t.parser.CommandType() == lCommand {
+				note = t.describeSymbol(t.canonical(strings.TrimSpace(t.parser.Symbol())))
+			} else if t.parser.CommandType() == directiveCommand {
+				note = t.describeDirective()
+			}
+			t.writeListingLine(0, false, note)
+			continue
+		}
+		t.words = append(t.words, word)
+		t.writeListingLine(word, true, note)
+		t.sourceMap = append(t.sourceMap, t.location())
+		t.currentROM++
+	}
+	t.collectStats()
+}
+
+// location returns where the current command came from, at the current ROM
+// address.
+func (t *translateInstruction) location() SourceLocation {
+	return SourceLocation{ROM: t.currentROM, File: t.parser.File(), Line: t.parser.Line()}
+}
+
+// describeSymbol says what a symbol resolved to, for the listing.
+func (t *translateInstruction) describeSymbol(symbol string) string {
+	if _, isLabel := t.labels[symbol]; isLabel {
+		return fmt.Sprintf("%s = ROM[%d]", symbol, t.symbolTable[symbol])
+	}
+	return fmt.Sprintf("%s = RAM[%d]", symbol, t.symbolTable[symbol])
+}
+
+func (t *translateInstruction) writeListingHeader() {
+	if t.options.Listing == nil {
+		return
+	}
+	fmt.Fprintf(&t.listing, "%-5s  %-4s  %-16s  %5s  %s\n", "ROM", "HEX", "BINARY", "LINE", "SOURCE")
+	t.listedFile, t.listedRow = "", 0
+}
+
+// writeListingLine adds the current source line to the listing. When
+// hasWord is false, as for labels and directives, the ROM, HEX and BINARY
+// columns are left blank. The source is shown as written; further rows
+// expanded from the same line, such as a macro body, show the expanded
+// command after a '+'. A header names the file whenever it changes.
+func (t *translateInstruction) writeListingLine(word uint16, hasWord bool, note string) {
+	if t.options.Listing == nil {
+		return
+	}
+	address, hex, binary := "", "", ""
+	if hasWord {
+		address, hex, binary = fmt.Sprintf("%05d", t.currentROM), fmt.Sprintf("%04X", word), fmt.Sprintf("%016b", word)
+	}
+	file, row := t.parser.File(), t.parser.Line()
+	if file != t.listedFile && file != "" {
+		if t.listedRow != 0 {
+			fmt.Fprintln(&t.listing)
+		}
+		fmt.Fprintf(&t.listing, "%s:\n", file)
+	}
+	source := t.parser.Source()
+	if file == t.listedFile && row == t.listedRow {
+		source = "+ " + t.parser.Command()
+	}
+	t.listedFile, t.listedRow = file, row
+	line := fmt.Sprintf("%-5s  %-4s  %-16s  %5d  %s", address, hex, binary, row, source)
+	if note != "" {
+		line = fmt.Sprintf("%-70s  ; %s", line, note)
+	}
+	fmt.Fprintln(&t.listing, strings.TrimRight(line, " "))
+}
+
+// encodeCCommand returns the machine word for the current C-command,
+// reporting every field it cannot encode.
+func (t *translateInstruction) encodeCCommand() (uint16, bool) {
+	command := t.parser.Command()
+	if strings.Count(command, "=") > 1 || strings.Count(command, ";") > 1 ||
+		strings.Contains(command, "=") && strings.Contains(command, ";") && strings.Index(command, ";") < strings.Index(command, "=") {
+		t.report(ErrMalformedInstruction, 0, "expected dest=comp;jump, got %q", command)
+		return 0, false
+	}
+
+	instruction := splitCInstruction(command)
+	ok := true
+	dest, exist := hackisa.ParseDest(instruction.dest.text)
+	if instruction.dest.text == "" {
+		t.report(ErrMalformedInstruction, instruction.dest.offset, "missing destination before '='")
+		ok = false
+	} else if !exist {
+		t.report(ErrUnknownDest, instruction.dest.offset, "unknown destination %q", instruction.dest.text)
+		ok = false
+	}
+	comp, exist := hackisa.ParseComp(instruction.comp.text)
+	if instruction.comp.text == "" {
+		t.report(ErrMalformedInstruction, instruction.comp.offset, "missing computation in %q", command)
+		ok = false
+	} else if !exist {
+		t.report(ErrUnknownComp, instruction.comp.offset, "unknown computation %q", instruction.comp.text)
+		ok = false
+	}
+	jump, exist := hackisa.ParseJump(instruction.jump.text)
+	if instruction.jump.text == "" {
+		t.report(ErrMalformedInstruction, instruction.jump.offset, "missing jump after ';'")
+		ok = false
+	} else if !exist {
+		t.report(ErrUnknownJump, instruction.jump.offset, "unknown jump %q", instruction.jump.text)
+		ok = false
+	}
+	if !ok {
+		return 0, false
+	}
+	return hackisa.C(dest, comp, jump).Encode(), true
+}
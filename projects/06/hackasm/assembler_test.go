@@ -0,0 +1,117 @@
+package hackasm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// assemble runs the assembler on source and returns the .hack output and
+// the diagnostics.
+func assemble(t *testing.T, source string, options Options) (string, []Diagnostic) {
+	t.Helper()
+	if options.FileName == "" {
+		options.FileName = "test.asm"
+	}
+	var output bytes.Buffer
+	diagnostics, err := Assemble(strings.NewReader(source), &output, options)
+	if err != nil {
+		t.Fatalf("Assemble: %v", err)
+	}
+	return output.String(), diagnostics
+}
+
+// hasCode reports whether diagnostics contain one with the given code.
+func hasCode(diagnostics []Diagnostic, code Code) bool {
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func TestReserveTooLarge(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"huge", ".reserve big 9999999999\n"},
+		{"past the screen", ".reserve big 16369\n"},
+		{"after other data", ".reserve small 100\n.reserve big 16300\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, diagnostics := assemble(t, test.source, Options{})
+			if !hasCode(diagnostics, ErrRAMOverflow) {
+				t.Errorf("want %s, got %v", ErrRAMOverflow, diagnostics)
+			}
+		})
+	}
+}
+
+func TestReserveFits(t *testing.T) {
+	var ram bytes.Buffer
+	_, diagnostics := assemble(t, ".reserve big 16368\n@big\n", Options{RAM: &ram})
+	if HasErrors(diagnostics) {
+		t.Fatalf("unexpected errors: %v", diagnostics)
+	}
+	if lines := strings.Count(ram.String(), "\n"); lines != 16368 {
+		t.Errorf("RAM image has %d words, want 16368", lines)
+	}
+}
+
+func TestMacroLabelsInPseudoInstructions(t *testing.T) {
+	source := `.macro SPIN
+(top)
+    LOAD D, top
+    JMP top
+.endm
+SPIN
+SPIN
+`
+	want := strings.Join([]string{
+		"0000000000000000", // @SPIN$top.1
+		"1110110000010000", // D=A
+		"0000000000000000", // @SPIN$top.1
+		"1110101010000111", // 0;JMP
+		"0000000000000100", // @SPIN$top.2
+		"1110110000010000",
+		"0000000000000100",
+		"1110101010000111",
+	}, "\n") + "\n"
+	output, diagnostics := assemble(t, source, Options{})
+	if len(diagnostics) > 0 {
+		t.Fatalf("unexpected diagnostics: %v", diagnostics)
+	}
+	if output != want {
+		t.Errorf("got\n%s\nwant\n%s", output, want)
+	}
+}
+
+func TestListingShowsSource(t *testing.T) {
+	sources := []Source{
+		{Name: "a.asm", Reader: strings.NewReader(".macro SPIN\n(top)\n    JMP top\n.endm\n(.loop)  // local\nSPIN\n@.loop\n")},
+		{Name: "b.asm", Reader: strings.NewReader("@x\nM=1\n")},
+	}
+	var output, listing bytes.Buffer
+	diagnostics, err := AssembleSources(sources, &output, Options{Listing: &listing})
+	if err != nil || len(diagnostics) > 0 {
+		t.Fatalf("AssembleSources: %v %v", err, diagnostics)
+	}
+	want := `ROM    HEX   BINARY             LINE  SOURCE
+a.asm:
+                                   5  (.loop)  // local                 ; a:.loop = ROM[0]
+                                   6  SPIN                              ; SPIN$top.1 = ROM[0]
+00000  0000  0000000000000000      6  + @SPIN$top.1                     ; SPIN$top.1 = ROM[0]
+00001  EA87  1110101010000111      6  + 0;JMP
+00002  0000  0000000000000000      7  @.loop                            ; a:.loop = ROM[0]
+
+b.asm:
+00003  0010  0000000000010000      1  @x                                ; x = RAM[16]
+00004  EFC8  1110111111001000      2  M=1
+`
+	if listing.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", listing.String(), want)
+	}
+}
@@ -0,0 +1,79 @@
+package hackasm
+
+import (
+	"strings"
+
+	"github.com/MinhNHHH/nand2tetris/projects/06/hackisa"
+)
+
+// cField is one part of a C-instruction with the offset of its first
+// non-blank character inside the command, used to place diagnostics.
+type cField struct {
+	text   string
+	offset int
+}
+
+type cInstruction struct {
+	dest cField
+	comp cField
+	jump cField
+}
+
+// splitCInstruction breaks dest=comp;jump into its fields. Omitted dest
+// and jump become "null"; whitespace inside a field is dropped, so
+// "AM = M + 1 ; JGT" is accepted.
+func splitCInstruction(command string) cInstruction {
+	instruction := cInstruction{
+		dest: cField{text: "null"},
+		jump: cField{text: "null"},
+	}
+	rest, restOffset := command, 0
+	if index := strings.Index(rest, "="); index != -1 {
+		instruction.dest = newCField(rest[:index], 0)
+		rest, restOffset = rest[index+1:], index+1
+	}
+	if index := strings.Index(rest, ";"); index != -1 {
+		instruction.jump = newCField(rest[index+1:], restOffset+index+1)
+		rest = rest[:index]
+	}
+	instruction.comp = newCField(rest, restOffset)
+	return instruction
+}
+
+func newCField(text string, offset int) cField {
+	offset += len(text) - len(strings.TrimLeft(text, " \t"))
+	return cField{
+		text:   strings.Join(strings.Fields(text), ""),
+		offset: offset,
+	}
+}
+
+// parseCInstruction encodes a whole C-command, or reports false if any
+// field is not a valid mnemonic.
+func parseCInstruction(command string) (hackisa.Instruction, bool) {
+	instruction := splitCInstruction(command)
+	dest, destOK := hackisa.ParseDest(instruction.dest.text)
+	comp, compOK := hackisa.ParseComp(instruction.comp.text)
+	jump, jumpOK := hackisa.ParseJump(instruction.jump.text)
+	return hackisa.C(dest, comp, jump), destOK && compOK && jumpOK
+}
+
+// normalizeDest accepts the destination registers in any order, e.g. DM
+// or MAD, and returns the canonical spelling.
+func normalizeDest(dest string) (string, bool) {
+	parsed, ok := hackisa.ParseDest(dest)
+	if !ok {
+		return "", false
+	}
+	return parsed.String(), true
+}
+
+// normalizeComp maps commutative spellings such as A+D, M&D or 1+D onto
+// the spelling of the Hack specification.
+func normalizeComp(comp string) (string, bool) {
+	parsed, ok := hackisa.ParseComp(comp)
+	if !ok {
+		return "", false
+	}
+	return parsed.String(), true
+}
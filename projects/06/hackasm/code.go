@@ -0,0 +1,38 @@
+package hackasm
+
+// predefinedSymbols contain key is label and value is RAM address.
+var predefinedSymbols = map[string]int{
+	"SP":     0,
+	"LCL":    1,
+	"ARG":    2,
+	"THIS":   3,
+	"THAT":   4,
+	"SCREEN": 16384,
+	"KBD":    24576,
+	"R0":     0,
+	"R1":     1,
+	"R2":     2,
+	"R3":     3,
+	"R4":     4,
+	"R5":     5,
+	"R6":     6,
+	"R7":     7,
+	"R8":     8,
+	"R9":     9,
+	"R10":    10,
+	"R11":    11,
+	"R12":    12,
+	"R13":    13,
+	"R14":    14,
+	"R15":    15,
+}
+
+// newSymbolTable returns a fresh copy of the predefined symbols so every
+// assembly starts from the same state.
+func newSymbolTable() map[string]int {
+	table := make(map[string]int, len(predefinedSymbols))
+	for symbol, address := range predefinedSymbols {
+		table[symbol] = address
+	}
+	return table
+}
@@ -0,0 +1,161 @@
+package hackasm
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// dataBlock is RAM reserved by a .data, .string or .reserve directive.
+// Blocks are allocated in source order from address 16, before any
+// implicitly created variable.
+type dataBlock struct {
+	name     string
+	address  int
+	words    []uint16
+	location SourceLocation
+}
+
+// defineDirective handles a directive during the first pass:
+//
+//	.data name 1, -2, 0x3   // one word per value
+//	.string name "text"     // one word per character, then a 0 terminator
+//	.reserve name N         // N words initialised to 0
+//	.var name [address]     // a declared variable, see declareVariable
+func (t *translateInstruction) defineDirective() {
+	directive, rest := splitWord(t.parser.Command())
+	name, operands := splitWord(rest)
+	var words []uint16
+	switch directive {
+	case ".var":
+		t.declareVariable(name, operands)
+		return
+	case ".data":
+		if operands == "" {
+			t.report(ErrDirective, 0, ".data %s needs at least one value", name)
+			return
+		}
+		for _, operand := range splitOperands(operands) {
+			value, ok := t.parseDataValue(operand)
+			if !ok {
+				return
+			}
+			words = append(words, value)
+		}
+	case ".string":
+		text, err := strconv.Unquote(operands)
+		if err != nil {
+			t.report(ErrDirective, 0, "expected .string name \"text\", got %q", operands)
+			return
+		}
+		for _, char := range text {
+			if char > 0x7FFF {
+				t.report(ErrDataValue, 0, "character %q does not fit in a Hack word", char)
+				return
+			}
+			words = append(words, uint16(char))
+		}
+		words = append(words, 0)
+	case ".reserve":
+		size, err := strconv.Atoi(operands)
+		if err != nil || size <= 0 {
+			t.report(ErrDirective, 0, "expected .reserve name N with N > 0, got %q", operands)
+			return
+		}
+		// Check the size before allocating it: a typo such as an extra
+		// digit would otherwise exhaust the memory of the assembler itself.
+		if room := t.options.Memory.ScreenBase - t.currentAddress; size > room {
+			t.report(ErrRAMOverflow, 0, "%s of %d words at RAM[%d] runs into the memory-mapped I/O at %d", name, size, t.currentAddress, t.options.Memory.ScreenBase)
+			return
+		}
+		words = make([]uint16, size)
+	default:
+		t.report(ErrDirective, 0, "unknown directive %s", directive)
+		return
+	}
+
+	if name == "" {
+		t.report(ErrDirective, 0, "%s needs a name", directive)
+		return
+	}
+	name, ok := t.symbolName(name, len(directive)+1)
+	if !ok {
+		return
+	}
+	if previous, exist := t.definition(name); exist {
+		t.report(ErrDuplicateLabel, len(directive)+1, "symbol %q already defined at %s:%d", name, previous.File, previous.Line)
+		return
+	}
+	if _, exist := predefinedSymbols[name]; exist {
+		t.report(ErrDuplicateLabel, len(directive)+1, "symbol %q is predefined", name)
+		return
+	}
+	address := t.allocateRAM(name, len(words))
+	t.data = append(t.data, dataBlock{name: name, address: address, words: words, location: t.location()})
+	t.symbolTable[name] = address
+}
+
+// parseDataValue accepts the same literals as A-instructions, but over the
+// full 16 bits: -32768..65535.
+func (t *translateInstruction) parseDataValue(operand string) (uint16, bool) {
+	if strings.HasPrefix(operand, "-") && len(operand) > 1 {
+		value, err := strconv.Atoi(operand)
+		if err != nil || value < -32768 {
+			t.report(ErrDataValue, 0, "data value %q is not a 16-bit number", operand)
+			return 0, false
+		}
+		return uint16(int16(value)), true
+	}
+	if operand == "" || !isConstant(operand) {
+		t.report(ErrDataValue, 0, "data value %q is not a number", operand)
+		return 0, false
+	}
+	value, ok := t.parseConstant(operand, 0, 0xFFFF)
+	return uint16(value), ok
+}
+
+// definition returns where a label, data block or declared variable was
+// defined.
+func (t *translateInstruction) definition(symbol string) (SourceLocation, bool) {
+	if location, exist := t.labels[symbol]; exist {
+		return location, true
+	}
+	if t.declared[symbol] {
+		return t.variables[symbol], true
+	}
+	for _, block := range t.data {
+		if block.name == symbol {
+			return block.location, true
+		}
+	}
+	return SourceLocation{}, false
+}
+
+// describeDirective says where a data directive was placed, for the listing.
+func (t *translateInstruction) describeDirective() string {
+	_, rest := splitWord(t.parser.Command())
+	name, _ := splitWord(rest)
+	for _, block := range t.data {
+		if block.name == name && block.location.Line == t.parser.Line() && block.location.File == t.parser.File() {
+			return fmt.Sprintf("%s = RAM[%d..%d]", name, block.address, block.address+len(block.words)-1)
+		}
+	}
+	if t.declared[name] {
+		return fmt.Sprintf("%s = RAM[%d]", name, t.symbolTable[name])
+	}
+	return ""
+}
+
+// writeRAMImage writes one "address binary" line per initialised RAM word,
+// which an emulator can preload before running the ROM.
+func writeRAMImage(w io.Writer, blocks []dataBlock) error {
+	for _, block := range blocks {
+		for offset, word := range block.words {
+			if _, err := fmt.Fprintf(w, "%d %016b\n", block.address+offset, word); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package hackasm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Code identifies the kind of a diagnostic so tools can filter on it
+// without matching message text.
+type Code string
+
+const (
+	ErrMalformedLabel       Code = "E001"
+	ErrEmptySymbol          Code = "E002"
+	ErrDuplicateLabel       Code = "E003"
+	ErrUnknownDest          Code = "E004"
+	ErrUnknownComp          Code = "E005"
+	ErrUnknownJump          Code = "E006"
+	ErrMalformedInstruction Code = "E007"
+	ErrMalformedConstant    Code = "E008"
+	ErrConstantOutOfRange   Code = "E009"
+	ErrMacroSyntax          Code = "E010"
+	ErrDuplicateMacro       Code = "E011"
+	ErrMacroArguments       Code = "E012"
+	ErrRecursiveMacro       Code = "E013"
+	ErrPseudoOperand        Code = "E014"
+	ErrInclude              Code = "E015"
+	ErrIncludeCycle         Code = "E016"
+	ErrDirective            Code = "E017"
+	ErrDataValue            Code = "E018"
+	ErrMalformedWord        Code = "E019"
+	ErrInvalidInstruction   Code = "E020"
+	ErrROMOverflow          Code = "E021"
+	ErrRAMOverflow          Code = "E022"
+	ErrUndeclaredSymbol     Code = "E023"
+	ErrIllegalSymbol        Code = "E024"
+	ErrCaseCollision        Code = "E025"
+
+	WarnVariableSpill     Code = "W001"
+	WarnUnusedLabel       Code = "W002"
+	WarnSingleUseVariable Code = "W003"
+	WarnLabelAsAddress    Code = "W004"
+	WarnJumpFromMemory    Code = "W005"
+	WarnJumpWritesA       Code = "W006"
+)
+
+// Diagnostic is a problem found in the source while assembling. Line and
+// Column are 1-based and point into the original source text.
+type Diagnostic struct {
+	File     string
+	Line     int
+	Column   int
+	Severity Severity
+	Code     Code
+	Message  string
+}
+
+// String formats the diagnostic the way compilers do:
+// file:line:col: error: message [code].
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s [%s]", d.File, d.Line, d.Column, d.Severity, d.Message, d.Code)
+}
+
+func newError(line sourceLine, code Code, format string, args ...interface{}) Diagnostic {
+	return Diagnostic{
+		File:     line.file,
+		Line:     line.row,
+		Column:   len(line.text) - len(strings.TrimLeft(line.text, " \t")) + 1,
+		Severity: SeverityError,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// HasErrors reports whether any diagnostic is an error rather than a warning.
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// sortDiagnostics orders diagnostics by source position, since the two
+// passes find problems out of order.
+func sortDiagnostics(diagnostics []Diagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].File != diagnostics[j].File {
+			return diagnostics[i].File < diagnostics[j].File
+		}
+		if diagnostics[i].Line != diagnostics[j].Line {
+			return diagnostics[i].Line < diagnostics[j].Line
+		}
+		return diagnostics[i].Column < diagnostics[j].Column
+	})
+}
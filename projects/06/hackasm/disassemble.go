@@ -0,0 +1,93 @@
+package hackasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/MinhNHHH/nand2tetris/projects/06/hackisa"
+)
+
+// decodedWord is one ROM word read back from a .hack file.
+type decodedWord struct {
+	line        int
+	value       uint16
+	instruction string
+	valid       bool
+}
+
+// Disassemble reads a .hack file (one 16-character binary word per line)
+// from r and writes equivalent Hack assembly to w. Jump targets loaded with
+// @addr right before a jump get a synthesized (L<addr>) label. Words that
+// are not valid instructions are written as comments and reported.
+func Disassemble(r io.Reader, w io.Writer, fileName string) ([]Diagnostic, error) {
+	diagnostics := []Diagnostic{}
+	words := []decodedWord{}
+	scanner := bufio.NewScanner(r)
+	for row := 1; scanner.Scan(); row++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		value, err := strconv.ParseUint(line, 2, 16)
+		if err != nil || len(line) != 16 {
+			diagnostics = append(diagnostics, Diagnostic{File: fileName, Line: row, Column: 1, Severity: SeverityError, Code: ErrMalformedWord, Message: fmt.Sprintf("%q is not a 16-bit binary word", line)})
+			continue
+		}
+		instruction, column, problem := decodeWord(uint16(value))
+		if problem != "" {
+			diagnostics = append(diagnostics, Diagnostic{File: fileName, Line: row, Column: column, Severity: SeverityError, Code: ErrInvalidInstruction, Message: problem})
+		}
+		words = append(words, decodedWord{line: row, value: uint16(value), instruction: instruction, valid: problem == ""})
+	}
+	if err := scanner.Err(); err != nil {
+		return diagnostics, err
+	}
+
+	labels := jumpTargets(words)
+	output := bufio.NewWriter(w)
+	for address, word := range words {
+		if labels[address] {
+			fmt.Fprintf(output, "(L%d)\n", address)
+		}
+		switch {
+		case !word.valid:
+			fmt.Fprintf(output, "// invalid instruction %016b\n", word.value)
+		case word.value&0x8000 == 0 && labels[int(word.value)] && address+1 < len(words) && isJump(words[address+1]):
+			fmt.Fprintf(output, "@L%d\n", word.value)
+		default:
+			fmt.Fprintln(output, word.instruction)
+		}
+	}
+	return diagnostics, output.Flush()
+}
+
+// decodeWord turns one word into canonical assembly. For invalid words it
+// returns the 1-based bit column of the problem and a description.
+func decodeWord(value uint16) (string, int, string) {
+	instruction, err := hackisa.Decode(value)
+	if err != nil {
+		decodeErr := err.(*hackisa.DecodeError)
+		return "", decodeErr.Bit, decodeErr.Error()
+	}
+	return instruction.String(), 0, ""
+}
+
+func isJump(word decodedWord) bool {
+	return word.valid && word.value&0x8000 != 0 && word.value&0x7 != 0
+}
+
+// jumpTargets returns the ROM addresses loaded by an A-instruction that is
+// directly followed by a jump.
+func jumpTargets(words []decodedWord) map[int]bool {
+	targets := map[int]bool{}
+	for address := 0; address+1 < len(words); address++ {
+		value := int(words[address].value)
+		if words[address].value&0x8000 == 0 && isJump(words[address+1]) && value < len(words) {
+			targets[value] = true
+		}
+	}
+	return targets
+}
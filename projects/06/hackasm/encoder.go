@@ -0,0 +1,150 @@
+package hackasm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Encoder writes an assembled ROM image in one file format.
+type Encoder interface {
+	Encode(w io.Writer, words []uint16) error
+	// Extension is the usual file extension for the format, with the dot.
+	Extension() string
+}
+
+var encoders = map[string]Encoder{
+	"hack":     HackEncoder{},
+	"ihex":     IntelHexEncoder{},
+	"bin-le":   RawEncoder{Order: binary.LittleEndian},
+	"bin-be":   RawEncoder{Order: binary.BigEndian},
+	"logisim":  LogisimEncoder{},
+	"readmemb": ReadmemEncoder{},
+	"readmemh": ReadmemEncoder{Hex: true},
+}
+
+// LookupEncoder returns the encoder registered under name.
+func LookupEncoder(name string) (Encoder, bool) {
+	encoder, exist := encoders[name]
+	return encoder, exist
+}
+
+// EncoderNames lists the names accepted by LookupEncoder.
+func EncoderNames() []string {
+	names := make([]string, 0, len(encoders))
+	for name := range encoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HackEncoder writes the course's .hack format: one word per line as 16
+// ASCII '0'/'1' characters.
+type HackEncoder struct{}
+
+func (HackEncoder) Extension() string { return ".hack" }
+
+func (HackEncoder) Encode(w io.Writer, words []uint16) error {
+	output := bufio.NewWriter(w)
+	for _, word := range words {
+		fmt.Fprintf(output, "%016b\n", word)
+	}
+	return output.Flush()
+}
+
+// IntelHexEncoder writes Intel HEX records, two bytes per word with the
+// high byte first, 16 bytes per record.
+type IntelHexEncoder struct{}
+
+func (IntelHexEncoder) Extension() string { return ".hex" }
+
+func (IntelHexEncoder) Encode(w io.Writer, words []uint16) error {
+	output := bufio.NewWriter(w)
+	bytes := make([]byte, 0, len(words)*2)
+	for _, word := range words {
+		bytes = binary.BigEndian.AppendUint16(bytes, word)
+	}
+	upper := 0
+	for start := 0; start < len(bytes); start += 16 {
+		if start>>16 != upper {
+			upper = start >> 16
+			writeHexRecord(output, 0, 0x04, []byte{byte(upper >> 8), byte(upper)})
+		}
+		end := start + 16
+		if end > len(bytes) {
+			end = len(bytes)
+		}
+		writeHexRecord(output, start&0xFFFF, 0x00, bytes[start:end])
+	}
+	writeHexRecord(output, 0, 0x01, nil)
+	return output.Flush()
+}
+
+func writeHexRecord(w io.Writer, address int, recordType byte, data []byte) {
+	checksum := byte(len(data)) + byte(address>>8) + byte(address) + recordType
+	fmt.Fprintf(w, ":%02X%04X%02X", len(data), address, recordType)
+	for _, b := range data {
+		fmt.Fprintf(w, "%02X", b)
+		checksum += b
+	}
+	fmt.Fprintf(w, "%02X\n", -checksum)
+}
+
+// RawEncoder writes the words as plain bytes in the given byte order.
+type RawEncoder struct {
+	Order binary.ByteOrder
+}
+
+func (RawEncoder) Extension() string { return ".bin" }
+
+func (e RawEncoder) Encode(w io.Writer, words []uint16) error {
+	return binary.Write(w, e.Order, words)
+}
+
+// LogisimEncoder writes a Logisim "v2.0 raw" memory image that can be
+// loaded into a ROM component.
+type LogisimEncoder struct{}
+
+func (LogisimEncoder) Extension() string { return ".rom" }
+
+func (LogisimEncoder) Encode(w io.Writer, words []uint16) error {
+	output := bufio.NewWriter(w)
+	fmt.Fprintln(output, "v2.0 raw")
+	for index, word := range words {
+		separator := " "
+		if index%8 == 7 || index == len(words)-1 {
+			separator = "\n"
+		}
+		fmt.Fprintf(output, "%04x%s", word, separator)
+	}
+	return output.Flush()
+}
+
+// ReadmemEncoder writes a file for Verilog's $readmemb, or $readmemh when
+// Hex is set.
+type ReadmemEncoder struct {
+	Hex bool
+}
+
+func (e ReadmemEncoder) Extension() string {
+	if e.Hex {
+		return ".memh"
+	}
+	return ".memb"
+}
+
+func (e ReadmemEncoder) Encode(w io.Writer, words []uint16) error {
+	output := bufio.NewWriter(w)
+	fmt.Fprintf(output, "// Hack ROM image, %d words\n", len(words))
+	for address, word := range words {
+		if e.Hex {
+			fmt.Fprintf(output, "%04x // %d\n", word, address)
+		} else {
+			fmt.Fprintf(output, "%016b // %d\n", word, address)
+		}
+	}
+	return output.Flush()
+}
@@ -0,0 +1,97 @@
+package hackasm
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/MinhNHHH/nand2tetris/projects/06/hackisa"
+)
+
+// expandFile preprocesses one whole file, remembering it while its lines
+// are expanded so an include cycle back to it can be reported.
+func (p *preprocessor) expandFile(name string, lines []sourceLine) []sourceLine {
+	p.including = append(p.including, filepath.Clean(name))
+	defer func() { p.including = p.including[:len(p.including)-1] }()
+	return p.expand(lines)
+}
+
+// include handles `.include "file.asm"` by splicing in the expanded lines
+// of the named file.
+func (p *preprocessor) include(line sourceLine) []sourceLine {
+	argument := strings.TrimSpace(strings.TrimPrefix(stripComment(line.text), ".include"))
+	name, err := strconv.Unquote(argument)
+	if err != nil || name == "" {
+		p.report(line, ErrInclude, "expected .include \"file.asm\", got %q", argument)
+		return nil
+	}
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(filepath.Dir(line.file), name)
+	}
+	name = filepath.Clean(name)
+
+	for index, active := range p.including {
+		if active == name {
+			chain := append(append([]string{}, p.including[index:]...), name)
+			p.report(line, ErrIncludeCycle, "include cycle: %s", strings.Join(chain, " -> "))
+			return nil
+		}
+	}
+	if p.options.Open == nil {
+		p.report(line, ErrInclude, "cannot include %s: no file access configured", name)
+		return nil
+	}
+	file, err := p.options.Open(name)
+	if err != nil {
+		p.report(line, ErrInclude, "cannot include %s: %v", name, err)
+		return nil
+	}
+	defer file.Close()
+	lines, err := readLines(file, name)
+	if err != nil {
+		p.report(line, ErrInclude, "cannot read %s: %v", name, err)
+		return nil
+	}
+	return p.expandFile(name, lines)
+}
+
+// localizeLabels renames labels starting with '.' to FILE:.label so each
+// file gets its own copy. FILE is the base name of the source file with
+// characters not allowed in symbols replaced by '_', numbered when two
+// files share a base name.
+func localizeLabels(lines []sourceLine) []sourceLine {
+	scopes := map[string]string{}
+	used := map[string]int{}
+	scope := func(file string) string {
+		if name, exist := scopes[file]; exist {
+			return name
+		}
+		name := strings.Map(func(char rune) rune {
+			if hackisa.BadSymbolChar(string(char)) == -1 || char >= '0' && char <= '9' {
+				return char
+			}
+			return '_'
+		}, strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)))
+		if name == "" || name[0] >= '0' && name[0] <= '9' {
+			name = "_" + name
+		}
+		used[name]++
+		if used[name] > 1 {
+			name += "." + strconv.Itoa(used[name])
+		}
+		scopes[file] = name
+		return name
+	}
+
+	localized := make([]sourceLine, len(lines))
+	for index, line := range lines {
+		localized[index] = line
+		command := stripComment(line.text)
+		if strings.HasPrefix(command, "@.") {
+			localized[index].text = "@" + scope(line.file) + ":" + strings.TrimSpace(command[1:])
+		} else if strings.HasPrefix(command, "(") && strings.HasPrefix(strings.TrimSpace(command[1:]), ".") {
+			localized[index].text = "(" + scope(line.file) + ":" + strings.TrimSpace(command[1:])
+		}
+	}
+	return localized
+}
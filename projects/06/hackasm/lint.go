@@ -0,0 +1,65 @@
+package hackasm
+
+import "strings"
+
+// lint walks the program again after it assembled cleanly and warns about
+// code that is legal but probably wrong:
+//
+//   - labels that are never referenced,
+//   - variables referenced only once, usually a misspelt name that became
+//     a fresh RAM cell,
+//   - M used while A holds a ROM label rather than a RAM address,
+//   - jumps to an address that was loaded from memory,
+//   - C-instructions that both write A and jump, where the jump still uses
+//     the old A.
+func (t *translateInstruction) lint() {
+	references := map[string]int{}
+	aLabel, aFromMemory := "", false
+	t.parser.Reset()
+	for t.parser.HasMoreCommands() {
+		t.parser.Advance()
+		switch t.parser.CommandType() {
+		case lCommand:
+			// Code after a label can be reached with any A.
+			aLabel, aFromMemory = "", false
+		case aCommand:
+			symbol := t.parser.Symbol()
+			aLabel, aFromMemory = "", false
+			if symbol == "" || isConstant(symbol) {
+				continue
+			}
+			symbol = t.canonical(symbol)
+			references[symbol]++
+			if _, isLabel := t.labels[symbol]; isLabel {
+				aLabel = symbol
+			}
+		case cCommand:
+			instruction := splitCInstruction(t.parser.Command())
+			dest, _ := normalizeDest(instruction.dest.text)
+			comp, _ := normalizeComp(instruction.comp.text)
+			jumps := instruction.jump.text != "null"
+			if aLabel != "" && (strings.Contains(comp, "M") || strings.Contains(dest, "M")) {
+				t.warn(WarnLabelAsAddress, 0, "M refers to RAM[%d], but A holds the ROM label %q", t.symbolTable[aLabel], aLabel)
+			}
+			if jumps && strings.Contains(dest, "A") {
+				t.warn(WarnJumpWritesA, 0, "%q writes A and jumps; the jump uses the old value of A", t.parser.Command())
+			} else if jumps && aFromMemory {
+				t.warn(WarnJumpFromMemory, 0, "jump target was loaded from memory")
+			}
+			if strings.Contains(dest, "A") {
+				aLabel, aFromMemory = "", strings.Contains(comp, "M")
+			}
+		}
+	}
+
+	for name, location := range t.labels {
+		if references[name] == 0 {
+			t.warnAt(location, WarnUnusedLabel, "label %q is never used", name)
+		}
+	}
+	for name, location := range t.variables {
+		if references[name] == 1 && !t.declared[name] {
+			t.warnAt(location, WarnSingleUseVariable, "variable %q is used only once; is the name misspelt?", name)
+		}
+	}
+}
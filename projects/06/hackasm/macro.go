@@ -0,0 +1,228 @@
+package hackasm
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/MinhNHHH/nand2tetris/projects/06/hackisa"
+)
+
+// macro is a user definition written as
+//
+//	.macro NAME param1, param2
+//	    ... body, referring to parameters as %param1 ...
+//	.endm
+//
+// Labels defined inside the body are renamed on every expansion so a
+// macro can be used more than once.
+type macro struct {
+	name   string
+	params []string
+	body   []sourceLine
+	labels map[string]bool
+}
+
+type preprocessor struct {
+	options     Options
+	macros      map[string]*macro
+	expansions  int
+	including   []string
+	diagnostics []Diagnostic
+}
+
+func newPreprocessor(options Options) *preprocessor {
+	return &preprocessor{
+		options: options,
+		macros:  map[string]*macro{},
+	}
+}
+
+func (p *preprocessor) report(line sourceLine, code Code, format string, args ...interface{}) {
+	p.diagnostics = append(p.diagnostics, newError(line, code, format, args...))
+}
+
+// expand collects macro definitions and replaces every macro call and
+// pseudo-instruction with the instructions it stands for.
+func (p *preprocessor) expand(lines []sourceLine) []sourceLine {
+	expanded := []sourceLine{}
+	for index := 0; index < len(lines); index++ {
+		command := stripComment(lines[index].text)
+		switch directive(command) {
+		case ".macro":
+			index = p.define(lines, index)
+		case ".endm":
+			p.report(lines[index], ErrMacroSyntax, ".endm without .macro")
+		case ".include":
+			expanded = append(expanded, p.include(lines[index])...)
+		default:
+			expanded = append(expanded, p.expandLine(lines[index], nil)...)
+		}
+	}
+	return expanded
+}
+
+// directive returns the first word of a command when it is a dot directive.
+func directive(command string) string {
+	if !strings.HasPrefix(command, ".") {
+		return ""
+	}
+	return strings.Fields(command)[0]
+}
+
+// define reads the macro starting at lines[start] and returns the index of
+// its .endm line.
+func (p *preprocessor) define(lines []sourceLine, start int) int {
+	header := strings.TrimSpace(strings.TrimPrefix(stripComment(lines[start].text), ".macro"))
+	name, rest := splitWord(header)
+	m := &macro{name: name, labels: map[string]bool{}}
+	valid := true
+	if name == "" {
+		p.report(lines[start], ErrMacroSyntax, "missing macro name")
+		valid = false
+	} else if _, exist := p.macros[name]; exist {
+		p.report(lines[start], ErrDuplicateMacro, "macro %q already defined", name)
+		valid = false
+	} else if _, exist := pseudoInstructions[name]; exist {
+		p.report(lines[start], ErrDuplicateMacro, "macro %q would hide the %s pseudo-instruction", name, name)
+		valid = false
+	} else if index := hackisa.BadSymbolChar(name); index != -1 {
+		p.report(lines[start], ErrMacroSyntax, "illegal character %q in macro name %q", name[index], name)
+		valid = false
+	} else if _, isComp := normalizeComp(name); isComp {
+		p.report(lines[start], ErrMacroSyntax, "macro name %q is a valid computation", name)
+		valid = false
+	}
+	if rest != "" {
+		for _, param := range strings.Split(rest, ",") {
+			param = strings.TrimSpace(param)
+			if param == "" {
+				p.report(lines[start], ErrMacroSyntax, "empty parameter name in macro %q", name)
+				valid = false
+			}
+			m.params = append(m.params, param)
+		}
+	}
+
+	for index := start + 1; index < len(lines); index++ {
+		command := stripComment(lines[index].text)
+		switch directive(command) {
+		case ".endm":
+			if valid {
+				p.macros[name] = m
+			}
+			return index
+		case ".macro":
+			p.report(lines[index], ErrMacroSyntax, "nested .macro inside %q", name)
+			valid = false
+			continue
+		}
+		if strings.HasPrefix(command, "(") && strings.HasSuffix(command, ")") {
+			m.labels[strings.TrimSpace(command[1:len(command)-1])] = true
+		}
+		m.body = append(m.body, lines[index])
+	}
+	p.report(lines[start], ErrMacroSyntax, "macro %q has no matching .endm", name)
+	return len(lines)
+}
+
+// expandLine expands one line. stack holds the macros currently being
+// expanded so recursive definitions are reported instead of looping.
+func (p *preprocessor) expandLine(line sourceLine, stack []string) []sourceLine {
+	command := stripComment(line.text)
+	name, rest := splitWord(command)
+	if expansion, exist := pseudoInstructions[name]; exist {
+		instructions, err := expansion(splitOperands(rest))
+		if err != "" {
+			p.report(line, ErrPseudoOperand, "%s: %s", name, err)
+			return nil
+		}
+		return generatedLines(line, instructions)
+	}
+
+	m, exist := p.macros[name]
+	if !exist {
+		return []sourceLine{line}
+	}
+	for _, active := range stack {
+		if active == name {
+			p.report(line, ErrRecursiveMacro, "macro %q expands itself", name)
+			return nil
+		}
+	}
+	args := splitOperands(rest)
+	if len(args) != len(m.params) {
+		p.report(line, ErrMacroArguments, "macro %q takes %d argument(s), got %d", name, len(m.params), len(args))
+		return nil
+	}
+
+	p.expansions++
+	suffix := "." + strconv.Itoa(p.expansions)
+	expanded := []sourceLine{}
+	for _, bodyLine := range m.body {
+		text := m.substitute(stripComment(bodyLine.text), args)
+		// Rename labels once the line is expanded, so references made
+		// through pseudo-instructions such as JMP label are renamed too.
+		for _, expandedLine := range p.expandLine(sourceLine{file: line.file, row: line.row, text: text, source: line.source}, append(stack, name)) {
+			expandedLine.text = m.rename(expandedLine.text, suffix)
+			expanded = append(expanded, expandedLine)
+		}
+	}
+	return expanded
+}
+
+// substitute replaces %param references with the call's arguments.
+func (m *macro) substitute(command string, args []string) string {
+	// Longest names first so %ab is not partly replaced by %a.
+	order := make([]int, len(m.params))
+	for index := range order {
+		order[index] = index
+	}
+	sort.Slice(order, func(i, j int) bool { return len(m.params[order[i]]) > len(m.params[order[j]]) })
+	for _, index := range order {
+		command = strings.ReplaceAll(command, "%"+m.params[index], args[index])
+	}
+	return command
+}
+
+// rename renames the macro's own labels in an expanded instruction to
+// NAME$label.N.
+func (m *macro) rename(command string, suffix string) string {
+	if strings.HasPrefix(command, "(") && strings.HasSuffix(command, ")") {
+		label := strings.TrimSpace(command[1 : len(command)-1])
+		if m.labels[label] {
+			return "(" + m.name + "$" + label + suffix + ")"
+		}
+	} else if strings.HasPrefix(command, "@") && m.labels[strings.TrimSpace(command[1:])] {
+		return "@" + m.name + "$" + strings.TrimSpace(command[1:]) + suffix
+	}
+	return command
+}
+
+func splitWord(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	index := strings.IndexAny(text, " \t")
+	if index == -1 {
+		return text, ""
+	}
+	return text[:index], strings.TrimSpace(text[index:])
+}
+
+func splitOperands(text string) []string {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	operands := strings.Split(text, ",")
+	for index := range operands {
+		operands[index] = strings.TrimSpace(operands[index])
+	}
+	return operands
+}
+
+func generatedLines(origin sourceLine, instructions []string) []sourceLine {
+	lines := make([]sourceLine, len(instructions))
+	for index, instruction := range instructions {
+		lines[index] = sourceLine{file: origin.file, row: origin.row, text: instruction, source: origin.source}
+	}
+	return lines
+}
@@ -0,0 +1,110 @@
+package hackasm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MemoryMap holds the limits the assembler checks. The zero MemoryMap
+// stands for StandardMemory; any other is used as given, so to change one
+// limit start from StandardMemory.
+type MemoryMap struct {
+	// ROMSize is the number of instruction words available.
+	ROMSize int
+	// VariableBase is where variables and data are allocated from.
+	VariableBase int
+	// VariableLimit is where the stack starts. Variables at or past it
+	// still assemble but are reported as warnings.
+	VariableLimit int
+	// ScreenBase is the first memory-mapped I/O address. Variables
+	// reaching it are an error.
+	ScreenBase int
+}
+
+// StandardMemory is the memory map of the Hack platform: 32K words of ROM,
+// variables from RAM[16], the stack from RAM[256] and the screen from
+// RAM[16384].
+var StandardMemory = MemoryMap{ROMSize: 32768, VariableBase: 16, VariableLimit: 256, ScreenBase: 16384}
+
+func (m MemoryMap) withDefaults() MemoryMap {
+	if m == (MemoryMap{}) {
+		return StandardMemory
+	}
+	return m
+}
+
+// Stats reports figures about an assembled program.
+type Stats struct {
+	// OptimizedWords is the number of ROM words the optimizer removed.
+	OptimizedWords int
+	ROMWords       int
+	ROMSize        int
+	// RAMBase and RAMEnd bound the allocated variables and data:
+	// RAM[RAMBase] up to but not including RAM[RAMEnd].
+	RAMBase  int
+	RAMEnd   int
+	RAMLimit int
+	// Variables counts the variables allocated between RAMBase and
+	// RAMEnd; FixedVariables those declared with .var at an address of
+	// their own.
+	Variables      int
+	FixedVariables int
+	DataWords      int
+}
+
+// Summary describes ROM and RAM usage in a few lines.
+func (s Stats) Summary() string {
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "ROM: %d of %d words used (%.1f%%)\n", s.ROMWords, s.ROMSize, percent(s.ROMWords, s.ROMSize))
+	used := s.RAMEnd - s.RAMBase
+	fmt.Fprintf(&summary, "RAM: %d words at %d..%d (%d variables, %d data words), %d of %d before the stack (%.1f%%)\n",
+		used, s.RAMBase, s.RAMEnd-1, s.Variables, s.DataWords, used, s.RAMLimit-s.RAMBase, percent(used, s.RAMLimit-s.RAMBase))
+	if s.FixedVariables > 0 {
+		fmt.Fprintf(&summary, "RAM: plus %d variables declared at fixed addresses\n", s.FixedVariables)
+	}
+	if s.OptimizedWords > 0 {
+		fmt.Fprintf(&summary, "optimizer: saved %d ROM words\n", s.OptimizedWords)
+	}
+	return summary.String()
+}
+
+func percent(part int, whole int) float64 {
+	if whole <= 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(whole)
+}
+
+// allocateRAM reserves size words for name after the previous variable or
+// data block and checks the block against the memory map.
+func (t *translateInstruction) allocateRAM(name string, size int) int {
+	memory := t.options.Memory
+	address := t.currentAddress
+	t.currentAddress += size
+	end := t.currentAddress - 1
+	if end >= memory.ScreenBase {
+		t.report(ErrRAMOverflow, 0, "%s at RAM[%d] runs into the memory-mapped I/O at %d", name, end, memory.ScreenBase)
+	} else if end >= memory.VariableLimit {
+		t.warn(WarnVariableSpill, 0, "%s at RAM[%d] reaches RAM[%d], where the stack starts", name, end, memory.VariableLimit)
+	}
+	return address
+}
+
+func (t *translateInstruction) collectStats() {
+	if t.options.Stats == nil {
+		return
+	}
+	dataWords := 0
+	for _, block := range t.data {
+		dataWords += len(block.words)
+	}
+	stats := t.options.Stats
+	stats.ROMWords = t.currentROM
+	stats.ROMSize = t.options.Memory.ROMSize
+	stats.RAMBase = t.options.Memory.VariableBase
+	stats.RAMEnd = t.currentAddress
+	stats.RAMLimit = t.options.Memory.VariableLimit
+	stats.Variables = len(t.variables) - len(t.fixedVariables)
+	stats.FixedVariables = len(t.fixedVariables)
+	stats.DataWords = dataWords
+}
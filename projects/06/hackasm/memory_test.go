@@ -0,0 +1,37 @@
+package hackasm
+
+import "testing"
+
+func TestStatsVariables(t *testing.T) {
+	source := ".var a\n.var led 300\n.reserve buffer 4\n@a\nM=1\n@b\nM=1\n@led\nM=1\n"
+	var stats Stats
+	_, diagnostics := assemble(t, source, Options{Stats: &stats})
+	if len(diagnostics) > 0 {
+		t.Fatalf("unexpected diagnostics: %v", diagnostics)
+	}
+	want := Stats{ROMWords: 6, ROMSize: 32768, RAMBase: 16, RAMEnd: 22, RAMLimit: 256, Variables: 2, FixedVariables: 1, DataWords: 4}
+	if stats != want {
+		t.Errorf("got %+v, want %+v", stats, want)
+	}
+}
+
+func TestVariableBaseZero(t *testing.T) {
+	options := Options{Memory: StandardMemory}
+	options.Memory.VariableBase = 0
+	output, diagnostics := assemble(t, "@x\nM=1\n", options)
+	if len(diagnostics) > 0 {
+		t.Fatalf("unexpected diagnostics: %v", diagnostics)
+	}
+	if want := "0000000000000000\n1110111111001000\n"; output != want {
+		t.Errorf("got\n%s\nwant\n%s", output, want)
+	}
+}
+
+func TestScreenBase(t *testing.T) {
+	options := Options{Memory: StandardMemory}
+	options.Memory.ScreenBase = 18
+	_, diagnostics := assemble(t, "@x\nM=1\n@y\nM=1\n@z\nM=1\n", options)
+	if !hasCode(diagnostics, ErrRAMOverflow) {
+		t.Errorf("want %s, got %v", ErrRAMOverflow, diagnostics)
+	}
+}
@@ -0,0 +1,148 @@
+package hackasm
+
+import "strings"
+
+// optimize applies peephole rewrites to the expanded source until none
+// applies any more and returns the new lines with the number of ROM words
+// saved. Rewrites only look at straight-line code: a label always ends the
+// window, so no instruction that can be jumped to is changed.
+//
+//   - @SP, M=M+1, @SP, AM=M-1 (push then pop) becomes @SP, A=M.
+//   - An A-instruction immediately followed by another one is dropped.
+//   - Reloading the value A already holds is dropped.
+//   - A jump with no destination to the label that directly follows it is
+//     dropped, together with its @label when the code after the label
+//     loads A before using it.
+func optimize(lines []sourceLine) ([]sourceLine, int) {
+	before := countInstructions(lines)
+	for {
+		rewritten, changed := peephole(lines)
+		lines = rewritten
+		if !changed {
+			return lines, before - countInstructions(lines)
+		}
+	}
+}
+
+func countInstructions(lines []sourceLine) int {
+	count := 0
+	for _, line := range lines {
+		command := stripComment(line.text)
+		if command != "" && !strings.HasPrefix(command, "(") && !strings.HasPrefix(command, ".") {
+			count++
+		}
+	}
+	return count
+}
+
+// peepholeCommand is an instruction or label with its index in lines.
+type peepholeCommand struct {
+	index int
+	text  string
+}
+
+func peephole(lines []sourceLine) ([]sourceLine, bool) {
+	commands := []peepholeCommand{}
+	for index, line := range lines {
+		command := stripComment(line.text)
+		if command == "" || strings.HasPrefix(command, ".") {
+			continue
+		}
+		commands = append(commands, peepholeCommand{index: index, text: canonicalCommand(command)})
+	}
+
+	removed := map[int]bool{}
+	replaced := map[int]string{}
+	matches := func(at int, pattern ...string) bool {
+		if at+len(pattern) > len(commands) {
+			return false
+		}
+		for offset, text := range pattern {
+			if commands[at+offset].text != text || removed[commands[at+offset].index] {
+				return false
+			}
+		}
+		return true
+	}
+
+	knownA := ""
+	for at := 0; at < len(commands); at++ {
+		command := commands[at]
+		if removed[command.index] {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(command.text, "("):
+			knownA = ""
+		case matches(at, "@SP", "M=M+1", "@SP", "AM=M-1"):
+			replaced[commands[at+1].index] = "A=M"
+			removed[commands[at+2].index] = true
+			removed[commands[at+3].index] = true
+			knownA = ""
+			at++
+		case strings.HasPrefix(command.text, "@"):
+			if command.text == knownA {
+				removed[command.index] = true
+				continue
+			}
+			if at+1 < len(commands) && strings.HasPrefix(commands[at+1].text, "@") {
+				removed[command.index] = true
+				continue
+			}
+			if at+2 < len(commands) && isJumpTo(commands[at+1].text) && commands[at+2].text == "("+command.text[1:]+")" {
+				removed[commands[at+1].index] = true
+				if at+3 < len(commands) && strings.HasPrefix(commands[at+3].text, "@") {
+					removed[command.index] = true
+				}
+				continue
+			}
+			knownA = command.text
+		default:
+			if writesA(command.text) {
+				knownA = ""
+			}
+		}
+	}
+
+	if len(removed) == 0 && len(replaced) == 0 {
+		return lines, false
+	}
+	optimized := []sourceLine{}
+	for index, line := range lines {
+		if removed[index] {
+			continue
+		}
+		if text, exist := replaced[index]; exist {
+			line.text = text
+		}
+		optimized = append(optimized, line)
+	}
+	return optimized, true
+}
+
+// canonicalCommand spells C-instructions one way so patterns match
+// regardless of operand order or spacing. Anything it cannot parse is
+// returned unchanged and so never matches a pattern.
+func canonicalCommand(command string) string {
+	if strings.HasPrefix(command, "@") || strings.HasPrefix(command, "(") {
+		return strings.Join(strings.Fields(command), "")
+	}
+	instruction, ok := parseCInstruction(command)
+	if !ok {
+		return command
+	}
+	return instruction.String()
+}
+
+// isJumpTo reports whether a canonical C-instruction only jumps, so
+// removing it changes nothing but control flow.
+func isJumpTo(command string) bool {
+	return strings.Contains(command, ";") && !strings.Contains(command, "=")
+}
+
+// writesA reports whether a C-instruction may change the A register.
+// Anything unrecognised is assumed to.
+func writesA(command string) bool {
+	dest, ok := normalizeDest(splitCInstruction(command).dest.text)
+	return !ok || strings.Contains(dest, "A")
+}
@@ -0,0 +1,104 @@
+package hackasm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOptimizeRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		out   string
+		saved int
+	}{
+		{"push then pop", "@SP\nM=M+1\n@SP\nAM=M-1", "@SP\nA=M", 2},
+		{"push then pop, other spelling", "@SP\nM=1+M\n@SP\nMA=M-1", "@SP\nA=M", 2},
+		{"A-instruction overwritten", "@5\n@6\nD=A", "@6\nD=A", 1},
+		{"reload of A", "@5\nD=A\n@5\nM=D", "@5\nD=A\nM=D", 1},
+		{"reload after A changed", "@5\nA=M\n@5\nM=D", "@5\nA=M\n@5\nM=D", 0},
+		{"jump to the next line", "@END\n0;JMP\n(END)\nD=M", "@END\n(END)\nD=M", 1},
+		{"jump to the next line, then a load", "@END\n0;JMP\n(END)\n@1", "(END)\n@1", 2},
+		{"conditional jump to the next line", "@END\nD;JGT\n(END)\nD=M", "@END\n(END)\nD=M", 1},
+		{"jump elsewhere kept", "@LOOP\n0;JMP\n(END)\nD=M", "@LOOP\n0;JMP\n(END)\nD=M", 0},
+		{"label ends the window", "@5\nD=A\n(LOOP)\n@5\nD=A", "@5\nD=A\n(LOOP)\n@5\nD=A", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lines, err := readLines(strings.NewReader(test.in), "test.asm")
+			if err != nil {
+				t.Fatal(err)
+			}
+			optimized, saved := optimize(lines)
+			got := []string{}
+			for _, line := range optimized {
+				got = append(got, line.text)
+			}
+			if want := strings.Split(test.out, "\n"); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+			if saved != test.saved {
+				t.Errorf("saved %d words, want %d", saved, test.saved)
+			}
+		})
+	}
+}
+
+// Every line the optimizer would drop is still checked.
+func TestOptimizeKeepsErrors(t *testing.T) {
+	tests := []struct {
+		source  string
+		options Options
+		code    Code
+	}{
+		{"@40000\n@1\nD=A\n", Options{}, ErrConstantOutOfRange},
+		{"@a-b\n@1\nD=A\n", Options{}, ErrIllegalSymbol},
+		{"@typo\n@1\nD=A\n", Options{Strict: true}, ErrUndeclaredSymbol},
+		{"@5\nD=A\n@5\n@6\nD=A\n@x-\n@6\n", Options{}, ErrIllegalSymbol},
+		{"@END\nD=Q;JMP\n(END)\n@1\n", Options{}, ErrUnknownComp},
+	}
+	for _, test := range tests {
+		test.options.Optimize = true
+		_, diagnostics := assemble(t, test.source, test.options)
+		if !hasCode(diagnostics, test.code) {
+			t.Errorf("%q: want %s, got %v", test.source, test.code, diagnostics)
+		}
+	}
+}
+
+// Variables are allocated in source order, even when the optimizer drops
+// the first use of one.
+func TestOptimizeVariableOrder(t *testing.T) {
+	source := "@foo\n@bar\nM=1\n@foo\nM=1\n"
+	plain, _ := assemble(t, source, Options{})
+	optimized, diagnostics := assemble(t, source, Options{Optimize: true})
+	if len(diagnostics) > 0 {
+		t.Fatalf("unexpected diagnostics: %v", diagnostics)
+	}
+	want := "0000000000010001\n1110111111001000\n0000000000010000\n1110111111001000\n"
+	if optimized != want {
+		t.Errorf("got\n%s\nwant\n%s", optimized, want)
+	}
+	if !strings.HasSuffix(plain, want) {
+		t.Errorf("optimized code uses other addresses than\n%s", plain)
+	}
+}
+
+// A program may only fit in ROM once it is optimized.
+func TestOptimizeROMLimit(t *testing.T) {
+	source := "@1\n@2\n@3\nD=A\n"
+	options := Options{Memory: StandardMemory}
+	options.Memory.ROMSize = 2
+	if _, diagnostics := assemble(t, source, options); !hasCode(diagnostics, ErrROMOverflow) {
+		t.Errorf("want %s without the optimizer, got %v", ErrROMOverflow, diagnostics)
+	}
+	options.Optimize = true
+	if _, diagnostics := assemble(t, source, options); len(diagnostics) > 0 {
+		t.Errorf("unexpected diagnostics: %v", diagnostics)
+	}
+	options.Memory.ROMSize = 1
+	if _, diagnostics := assemble(t, source, options); !hasCode(diagnostics, ErrROMOverflow) {
+		t.Errorf("want %s for the optimized program, got %v", ErrROMOverflow, diagnostics)
+	}
+}
@@ -0,0 +1,141 @@
+package hackasm
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+type commandType int
+
+const (
+	noCommand commandType = iota - 1
+	aCommand
+	cCommand
+	lCommand
+	// directiveCommand is a line starting with '.', such as .data.
+	directiveCommand
+)
+
+// sourceLine is one line of input together with where it came from, so
+// lines produced by the preprocessor still point at the original source.
+// text is the line as the preprocessor rewrote it, source the line as it
+// was written.
+type sourceLine struct {
+	file   string
+	row    int
+	text   string
+	source string
+}
+
+type parser struct {
+	lines          []sourceLine
+	currentCommand string
+	currentRow     int
+	currentColumn  int
+}
+
+func newParser(lines []sourceLine) *parser {
+	return &parser{
+		lines: lines,
+	}
+}
+
+func readLines(r io.Reader, file string) ([]sourceLine, error) {
+	scanner := bufio.NewScanner(r)
+	lines := []sourceLine{}
+	for scanner.Scan() {
+		lines = append(lines, sourceLine{file: file, row: len(lines) + 1, text: scanner.Text(), source: scanner.Text()})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// stripComment returns the command on a line without its comment and
+// surrounding blanks.
+func stripComment(line string) string {
+	if index := strings.Index(line, "//"); index != -1 {
+		line = line[:index]
+	}
+	return strings.TrimSpace(line)
+}
+
+func (p *parser) HasMoreCommands() bool {
+	return p.currentRow < len(p.lines)
+}
+
+// Advance moves to the next source line. Blank and comment-only lines
+// leave the parser on noCommand.
+func (p *parser) Advance() {
+	line := p.lines[p.currentRow].text
+	p.currentRow += 1
+	p.currentColumn = len(line) - len(strings.TrimLeft(line, " \t")) + 1
+	p.currentCommand = stripComment(line)
+}
+
+func (p *parser) Reset() {
+	p.currentRow = 0
+	p.currentColumn = 0
+	p.currentCommand = ""
+}
+
+// File returns the file the current command was read from.
+func (p *parser) File() string {
+	if p.currentRow == 0 {
+		return ""
+	}
+	return p.lines[p.currentRow-1].file
+}
+
+// Line returns the 1-based source line of the current command.
+func (p *parser) Line() int {
+	if p.currentRow == 0 {
+		return 0
+	}
+	return p.lines[p.currentRow-1].row
+}
+
+// Column returns the 1-based source column where the current command starts.
+func (p *parser) Column() int {
+	return p.currentColumn
+}
+
+// Source returns the line the current command came from exactly as it
+// appears in the input, before includes, macros and local labels were
+// expanded.
+func (p *parser) Source() string {
+	if p.currentRow == 0 {
+		return ""
+	}
+	return p.lines[p.currentRow-1].source
+}
+
+func (p *parser) Command() string {
+	return p.currentCommand
+}
+
+func (p *parser) Symbol() string {
+	switch p.CommandType() {
+	case aCommand:
+		return p.currentCommand[1:]
+	case lCommand:
+		return strings.TrimSuffix(p.currentCommand[1:], ")")
+	}
+	return ""
+}
+
+func (p *parser) CommandType() commandType {
+	if p.currentCommand == "" {
+		return noCommand
+	}
+	if strings.HasPrefix(p.currentCommand, "@") {
+		return aCommand
+	} else if strings.HasPrefix(p.currentCommand, "(") {
+		return lCommand
+	} else if strings.HasPrefix(p.currentCommand, ".") {
+		return directiveCommand
+	}
+	return cCommand
+}
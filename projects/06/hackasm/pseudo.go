@@ -0,0 +1,41 @@
+package hackasm
+
+// pseudoInstructions are the built-in shorthands for common Hack idioms.
+// Each returns the instructions to emit, or a message explaining why the
+// operands are not accepted.
+var pseudoInstructions = map[string]func(operands []string) ([]string, string){
+	// PUSH D: *SP = D; SP++
+	"PUSH": func(operands []string) ([]string, string) {
+		if len(operands) != 1 || operands[0] != "D" {
+			return nil, "expected PUSH D"
+		}
+		return []string{"@SP", "M=M+1", "A=M-1", "M=D"}, ""
+	},
+	// POP D: SP--; D = *SP
+	"POP": func(operands []string) ([]string, string) {
+		if len(operands) != 1 || operands[0] != "D" {
+			return nil, "expected POP D"
+		}
+		return []string{"@SP", "AM=M-1", "D=M"}, ""
+	},
+	// JMP label: unconditional jump
+	"JMP": func(operands []string) ([]string, string) {
+		if len(operands) != 1 || operands[0] == "" {
+			return nil, "expected JMP label"
+		}
+		return []string{"@" + operands[0], "0;JMP"}, ""
+	},
+	// LOAD D, value or LOAD A, value: load a constant or symbol address
+	"LOAD": func(operands []string) ([]string, string) {
+		if len(operands) != 2 || operands[1] == "" {
+			return nil, "expected LOAD register, value"
+		}
+		switch operands[0] {
+		case "A":
+			return []string{"@" + operands[1]}, ""
+		case "D":
+			return []string{"@" + operands[1], "D=A"}, ""
+		}
+		return nil, "register must be A or D"
+	},
+}
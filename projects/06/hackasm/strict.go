@@ -0,0 +1,108 @@
+package hackasm
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/MinhNHHH/nand2tetris/projects/06/hackisa"
+)
+
+// declareVariable handles `.var name`, which allocates the next free RAM
+// word like an implicit variable would, and `.var name address`, which
+// binds the name to a fixed RAM address.
+func (t *translateInstruction) declareVariable(name string, operand string) {
+	if name == "" || isConstant(name) {
+		t.report(ErrDirective, 0, "expected .var name [address], got %q", strings.TrimSpace(name+" "+operand))
+		return
+	}
+	name, ok := t.symbolName(name, len(".var")+1)
+	if !ok {
+		return
+	}
+	if previous, exist := t.definition(name); exist {
+		t.report(ErrDuplicateLabel, len(".var")+1, "symbol %q already defined at %s:%d", name, previous.File, previous.Line)
+		return
+	}
+	if _, exist := predefinedSymbols[name]; exist {
+		t.report(ErrDuplicateLabel, len(".var")+1, "symbol %q is predefined", name)
+		return
+	}
+
+	if operand == "" {
+		t.symbolTable[name] = t.allocateRAM(name, 1)
+	} else {
+		if !isConstant(operand) {
+			t.report(ErrDirective, 0, "variable address %q is not a number", operand)
+			return
+		}
+		address, ok := t.parseConstant(operand, 0, hackisa.MaxAddress)
+		if !ok {
+			return
+		}
+		t.symbolTable[name] = address
+		t.fixedVariables[name] = true
+	}
+	t.variables[name] = t.location()
+	t.declared[name] = true
+}
+
+// reportUndeclared rejects a symbol in strict mode, suggesting the known
+// names closest to it.
+func (t *translateInstruction) reportUndeclared(symbol string) {
+	suggestions := t.suggest(symbol)
+	if len(suggestions) == 0 {
+		t.report(ErrUndeclaredSymbol, 1, "undeclared symbol %q", symbol)
+		return
+	}
+	t.report(ErrUndeclaredSymbol, 1, "undeclared symbol %q; did you mean %s?", symbol, strings.Join(suggestions, ", "))
+}
+
+// suggest returns up to three known symbols within a small edit distance
+// of symbol, closest first.
+func (t *translateInstruction) suggest(symbol string) []string {
+	type candidate struct {
+		name     string
+		distance int
+	}
+	limit := len(symbol)/3 + 1
+	candidates := []candidate{}
+	for name := range t.symbolTable {
+		distance := editDistance(strings.ToLower(symbol), strings.ToLower(name))
+		if distance <= limit {
+			candidates = append(candidates, candidate{name: name, distance: distance})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].name < candidates[j].name
+	})
+
+	suggestions := []string{}
+	for index := 0; index < len(candidates) && index < 3; index++ {
+		suggestions = append(suggestions, candidates[index].name)
+	}
+	return suggestions
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,80 @@
+package hackasm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MinhNHHH/nand2tetris/projects/06/hackisa"
+)
+
+// CaseMode says how symbols that differ only in letter case are treated.
+type CaseMode int
+
+const (
+	// CaseSensitive keeps loop and LOOP as two symbols, as the Hack
+	// specification does.
+	CaseSensitive CaseMode = iota
+	// CaseReject reports a second spelling of an existing symbol as an error.
+	CaseReject
+	// CaseInsensitive treats every spelling as the one seen first.
+	CaseInsensitive
+)
+
+// ParseCaseMode accepts "sensitive", "reject" or "insensitive".
+func ParseCaseMode(name string) (CaseMode, error) {
+	switch name {
+	case "sensitive":
+		return CaseSensitive, nil
+	case "reject":
+		return CaseReject, nil
+	case "insensitive":
+		return CaseInsensitive, nil
+	}
+	return CaseSensitive, fmt.Errorf("unknown case mode %q, expected sensitive, reject or insensitive", name)
+}
+
+func predefinedSpellings() map[string]string {
+	spellings := make(map[string]string, len(predefinedSymbols))
+	for symbol := range predefinedSymbols {
+		spellings[strings.ToLower(symbol)] = symbol
+	}
+	return spellings
+}
+
+// symbolName validates a symbol defined or used by the current command and
+// applies the case mode, returning the spelling to use in the symbol table.
+// offset is where the name starts in the command.
+func (t *translateInstruction) symbolName(name string, offset int) (string, bool) {
+	if index := hackisa.BadSymbolChar(name); index != -1 {
+		t.report(ErrIllegalSymbol, offset+index, "illegal character %q in symbol %q: symbols use letters, digits, '_', '.', '$' and ':' and cannot start with a digit", name[index], name)
+		return "", false
+	}
+	folded := strings.ToLower(name)
+	spelling, seen := t.spellings[folded]
+	if !seen {
+		t.spellings[folded] = name
+		return name, true
+	}
+	if spelling == name {
+		return name, true
+	}
+	switch t.options.Case {
+	case CaseReject:
+		t.report(ErrCaseCollision, offset, "symbol %q differs from %q only in case", name, spelling)
+		return "", false
+	case CaseInsensitive:
+		return spelling, true
+	}
+	return name, true
+}
+
+// canonical returns the spelling symbolName settled on, without reporting.
+func (t *translateInstruction) canonical(name string) string {
+	if t.options.Case != CaseInsensitive {
+		return name
+	}
+	if spelling, seen := t.spellings[strings.ToLower(name)]; seen {
+		return spelling
+	}
+	return name
+}
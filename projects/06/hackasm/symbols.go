@@ -0,0 +1,78 @@
+package hackasm
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// SymbolKind tells a debugger which memory a symbol's address refers to.
+type SymbolKind string
+
+const (
+	SymbolLabel    SymbolKind = "label"
+	SymbolVariable SymbolKind = "variable"
+	SymbolData     SymbolKind = "data"
+)
+
+type Symbol struct {
+	Name    string     `json:"name"`
+	Kind    SymbolKind `json:"kind"`
+	Address int        `json:"address"`
+	// File and Line are where a label is defined or a variable first used.
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// SourceLocation maps one ROM address back to the line it was assembled from.
+type SourceLocation struct {
+	ROM  int    `json:"rom"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// SymbolMap is the content of a .sym file: every label (ROM address) and
+// variable (RAM address) plus a ROM address to source line map.
+type SymbolMap struct {
+	File      string           `json:"file"`
+	Symbols   []Symbol         `json:"symbols"`
+	SourceMap []SourceLocation `json:"sourceMap"`
+}
+
+func (t *translateInstruction) symbolMap() SymbolMap {
+	symbolMap := SymbolMap{
+		File:      t.options.FileName,
+		Symbols:   []Symbol{},
+		SourceMap: t.sourceMap,
+	}
+	for name, location := range t.labels {
+		symbolMap.Symbols = append(symbolMap.Symbols, Symbol{Name: name, Kind: SymbolLabel, Address: t.symbolTable[name], File: location.File, Line: location.Line})
+	}
+	for _, block := range t.data {
+		symbolMap.Symbols = append(symbolMap.Symbols, Symbol{Name: block.name, Kind: SymbolData, Address: block.address, File: block.location.File, Line: block.location.Line})
+	}
+	for name, location := range t.variables {
+		symbolMap.Symbols = append(symbolMap.Symbols, Symbol{Name: name, Kind: SymbolVariable, Address: t.symbolTable[name], File: location.File, Line: location.Line})
+	}
+	sort.Slice(symbolMap.Symbols, func(i, j int) bool {
+		a, b := symbolMap.Symbols[i], symbolMap.Symbols[j]
+		// Labels (ROM) first, then data and variables (RAM) by address.
+		if (a.Kind == SymbolLabel) != (b.Kind == SymbolLabel) {
+			return a.Kind == SymbolLabel
+		}
+		if a.Address != b.Address {
+			return a.Address < b.Address
+		}
+		return a.Name < b.Name
+	})
+	if symbolMap.SourceMap == nil {
+		symbolMap.SourceMap = []SourceLocation{}
+	}
+	return symbolMap
+}
+
+func writeSymbolMap(w io.Writer, symbolMap SymbolMap) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(symbolMap)
+}
@@ -0,0 +1,199 @@
+// Package hackcpu emulates the Hack computer: the CPU with its A, D and PC
+// registers, a 32K instruction ROM and a 32K data RAM holding the
+// memory-mapped screen and keyboard.
+package hackcpu
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/MinhNHHH/nand2tetris/projects/06/hackisa"
+)
+
+const (
+	ROMSize = 1 << 15
+	RAMSize = 1 << 15
+	// Screen is the first word of the 8K screen memory map, KBD the
+	// keyboard register right after it.
+	Screen     = 16384
+	ScreenSize = 8192
+	KBD        = 24576
+)
+
+// Computer is a Hack computer. Memory starts out zeroed, like the hardware
+// after power-on.
+type Computer struct {
+	A, D, PC uint16
+	ROM      [ROMSize]uint16
+	RAM      [RAMSize]uint16
+	// Cycles counts the instructions executed since the last Reset.
+	Cycles int
+	// romWords is the size of the loaded program.
+	romWords int
+}
+
+func New() *Computer {
+	return &Computer{}
+}
+
+// LoadROM reads a .hack file, one 16-character binary word per line, into
+// ROM starting at address 0 and resets the CPU.
+func (c *Computer) LoadROM(r io.Reader) error {
+	words := 0
+	scanner := bufio.NewScanner(r)
+	for row := 1; scanner.Scan(); row++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		value, err := strconv.ParseUint(line, 2, 16)
+		if err != nil || len(line) != 16 {
+			return fmt.Errorf("line %d: %q is not a 16-bit binary word", row, line)
+		}
+		if words == ROMSize {
+			return fmt.Errorf("line %d: program does not fit in %d ROM words", row, ROMSize)
+		}
+		c.ROM[words] = uint16(value)
+		words++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	for address := words; address < c.romWords; address++ {
+		c.ROM[address] = 0
+	}
+	c.romWords = words
+	c.Reset()
+	return nil
+}
+
+// LoadRAM reads a RAM image as written by the assembler's -ram option:
+// lines of "address word" with the word in binary.
+func (c *Computer) LoadRAM(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for row := 1; scanner.Scan(); row++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return fmt.Errorf("line %d: expected \"address word\", got %q", row, scanner.Text())
+		}
+		address, err := strconv.ParseUint(fields[0], 10, 16)
+		if err != nil || address >= RAMSize {
+			return fmt.Errorf("line %d: bad RAM address %q", row, fields[0])
+		}
+		value, err := strconv.ParseUint(fields[1], 2, 16)
+		if err != nil {
+			return fmt.Errorf("line %d: %q is not a 16-bit binary word", row, fields[1])
+		}
+		c.RAM[address] = uint16(value)
+	}
+	return scanner.Err()
+}
+
+// Reset restarts the program from ROM[0]. Registers and RAM keep their
+// values, as on the real hardware.
+func (c *Computer) Reset() {
+	c.PC = 0
+	c.Cycles = 0
+}
+
+// SetKey presses a key, given as its Hack character code; 0 releases it.
+func (c *Computer) SetKey(code uint16) {
+	c.RAM[KBD] = code
+}
+
+// ScreenWords returns the screen memory map, 32 words per row of 512
+// pixels, 256 rows.
+func (c *Computer) ScreenWords() []uint16 {
+	return c.RAM[Screen : Screen+ScreenSize]
+}
+
+// Step executes the instruction at PC. Past the end of the program ROM
+// holds zeros, which run as @0; a PC outside ROM is an error.
+func (c *Computer) Step() error {
+	if int(c.PC) >= ROMSize {
+		return fmt.Errorf("PC %d is outside the %d-word ROM", c.PC, ROMSize)
+	}
+	instruction, err := hackisa.Decode(c.ROM[c.PC])
+	if err != nil {
+		return fmt.Errorf("ROM[%d]: %v", c.PC, err)
+	}
+	c.Cycles++
+	if instruction.Kind == hackisa.AInstruction {
+		c.A = instruction.Value
+		c.PC++
+		return nil
+	}
+
+	y := c.A
+	if instruction.Comp.UsesM() {
+		y = c.RAM[c.A&(RAMSize-1)]
+	}
+	out := instruction.Comp.Compute(c.D, y)
+	// The jump and the M write use A as it was before this instruction.
+	address := c.A
+	if instruction.Dest.WritesM() && address != KBD {
+		c.RAM[address&(RAMSize-1)] = out
+	}
+	if instruction.Dest.WritesA() {
+		c.A = out
+	}
+	if instruction.Dest.WritesD() {
+		c.D = out
+	}
+	if instruction.Jump.Taken(int16(out)) {
+		c.PC = address
+	} else {
+		c.PC++
+	}
+	return nil
+}
+
+// Halted reports whether the program is stuck in the conventional halt
+// loop, (END) @END 0;JMP, with PC on either of its two instructions, or in
+// a jump to itself, or has run off the end of the program. A jump outside
+// ROM is not a halt: the next Step reports it.
+func (c *Computer) Halted() bool {
+	pc := int(c.PC)
+	if pc >= ROMSize {
+		return false
+	}
+	if pc >= c.romWords {
+		return true
+	}
+	if c.ROM[pc]&0x8000 == 0 {
+		return int(c.ROM[pc]) == pc && pc+1 < c.romWords && isPlainJump(c.ROM[pc+1])
+	}
+	if !isPlainJump(c.ROM[pc]) {
+		return false
+	}
+	// On the 0;JMP of the halt loop A holds the address of its @END.
+	return int(c.A) == pc || (pc > 0 && int(c.A) == pc-1 && int(c.ROM[pc-1]) == pc-1)
+}
+
+// isPlainJump reports whether word always jumps and leaves A alone, so it
+// jumps to the same place every time.
+func isPlainJump(word uint16) bool {
+	instruction, err := hackisa.Decode(word)
+	return err == nil && instruction.Kind == hackisa.CInstruction &&
+		instruction.Jump == hackisa.JumpJMP && !instruction.Dest.WritesA()
+}
+
+// Run executes up to maxCycles instructions, stopping early at a halt loop.
+// It reports whether the program halted.
+func (c *Computer) Run(maxCycles int) (bool, error) {
+	for executed := 0; executed < maxCycles; executed++ {
+		if c.Halted() {
+			return true, nil
+		}
+		if err := c.Step(); err != nil {
+			return false, err
+		}
+	}
+	return c.Halted(), nil
+}
@@ -0,0 +1,157 @@
+package hackcpu
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/MinhNHHH/nand2tetris/projects/06/hackisa"
+)
+
+// load assembles a program of numeric A-instructions and C-instructions
+// and loads it into a new computer.
+func load(t *testing.T, program ...string) *Computer {
+	t.Helper()
+	var hack strings.Builder
+	for _, line := range program {
+		var instruction hackisa.Instruction
+		if value, ok := strings.CutPrefix(line, "@"); ok {
+			number, err := strconv.ParseUint(value, 10, 15)
+			if err != nil {
+				t.Fatalf("bad A-instruction %q", line)
+			}
+			instruction = hackisa.A(uint16(number))
+		} else {
+			destText, rest, hasDest := strings.Cut(line, "=")
+			if !hasDest {
+				destText, rest = "null", line
+			}
+			compText, jumpText, hasJump := strings.Cut(rest, ";")
+			if !hasJump {
+				jumpText = "null"
+			}
+			dest, destOK := hackisa.ParseDest(destText)
+			comp, compOK := hackisa.ParseComp(compText)
+			jump, jumpOK := hackisa.ParseJump(jumpText)
+			if !destOK || !compOK || !jumpOK {
+				t.Fatalf("bad C-instruction %q", line)
+			}
+			instruction = hackisa.C(dest, comp, jump)
+		}
+		fmt.Fprintf(&hack, "%016b\n", instruction.Encode())
+	}
+	c := New()
+	if err := c.LoadROM(strings.NewReader(hack.String())); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// step runs n instructions.
+func step(t *testing.T, c *Computer, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := c.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStepWritesM(t *testing.T) {
+	c := load(t, "@7", "D=A", "@100", "M=D+1")
+	step(t, c, 4)
+	if c.RAM[100] != 8 || c.D != 7 || c.A != 100 || c.PC != 4 || c.Cycles != 4 {
+		t.Errorf("RAM[100]=%d D=%d A=%d PC=%d Cycles=%d", c.RAM[100], c.D, c.A, c.PC, c.Cycles)
+	}
+}
+
+func TestStepIgnoresKBDWrite(t *testing.T) {
+	c := load(t, "@24576", "M=-1")
+	c.SetKey(65)
+	step(t, c, 2)
+	if c.RAM[KBD] != 65 {
+		t.Errorf("RAM[KBD] = %d, want the key 65", c.RAM[KBD])
+	}
+}
+
+// A jump goes to A as it was before the instruction, even when the same
+// instruction writes A.
+func TestStepJumpUsesOldA(t *testing.T) {
+	c := load(t, "@5", "AM=M-1;JMP")
+	c.RAM[5] = 10
+	step(t, c, 2)
+	if c.PC != 5 || c.A != 9 || c.RAM[5] != 9 {
+		t.Errorf("PC=%d A=%d RAM[5]=%d, want PC=5 A=9 RAM[5]=9", c.PC, c.A, c.RAM[5])
+	}
+}
+
+func TestStepConditionalJump(t *testing.T) {
+	c := load(t, "@3", "D=-1", "D;JGT", "D;JLT")
+	step(t, c, 3)
+	if c.PC != 3 {
+		t.Fatalf("JGT on -1: PC = %d, want 3", c.PC)
+	}
+	step(t, c, 1)
+	if c.PC != 3 {
+		t.Errorf("JLT on -1: PC = %d, want 3", c.PC)
+	}
+}
+
+func TestStepOutsideROM(t *testing.T) {
+	c := load(t, "D=0")
+	c.PC = ROMSize
+	if err := c.Step(); err == nil {
+		t.Error("Step with PC outside ROM did not fail")
+	}
+	if c.Cycles != 0 {
+		t.Errorf("Cycles = %d after a failed Step", c.Cycles)
+	}
+}
+
+func TestHalted(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []string
+		steps   int
+		halted  bool
+	}{
+		{"on the @ of the halt loop", []string{"D=0", "@1", "0;JMP"}, 1, true},
+		{"on the jump of the halt loop", []string{"D=0", "@1", "0;JMP"}, 2, true},
+		{"halt loop at address 0", []string{"@0", "0;JMP"}, 1, true},
+		{"jump to itself", []string{"@1", "0;JMP"}, 1, true},
+		{"past the end of the program", []string{"D=0"}, 1, true},
+		{"jump elsewhere", []string{"@3", "0;JMP", "D=0", "D=1"}, 1, false},
+		{"conditional jump", []string{"@0", "D;JEQ"}, 1, false},
+		{"jump that writes A", []string{"@0", "A=0;JMP"}, 1, false},
+		{"running", []string{"D=0", "D=1", "@2", "0;JMP"}, 1, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := load(t, test.program...)
+			step(t, c, test.steps)
+			if got := c.Halted(); got != test.halted {
+				t.Errorf("Halted() = %v with PC=%d A=%d, want %v", got, c.PC, c.A, test.halted)
+			}
+		})
+	}
+}
+
+func TestHaltedOutsideROM(t *testing.T) {
+	c := load(t, "D=0")
+	c.PC = ROMSize
+	if c.Halted() {
+		t.Error("a PC outside ROM counts as halted")
+	}
+}
+
+func TestRunStopsAtHalt(t *testing.T) {
+	c := load(t, "@2", "D=A", "@2", "0;JMP")
+	halted, err := c.Run(1000)
+	if err != nil || !halted {
+		t.Fatalf("Run = %v, %v", halted, err)
+	}
+	if c.D != 2 || c.Cycles > 4 {
+		t.Errorf("D=%d Cycles=%d", c.D, c.Cycles)
+	}
+}
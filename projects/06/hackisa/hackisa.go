@@ -0,0 +1,334 @@
+// Package hackisa defines the Hack instruction set: the A- and C-instruction
+// formats, the comp, dest and jump mnemonics, and how they map to 16-bit
+// words. The assembler, disassembler and emulator all encode and decode
+// through it.
+package hackisa
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Kind int
+
+const (
+	AInstruction Kind = iota
+	CInstruction
+)
+
+// MaxAddress is the largest value an A-instruction can load; bit 15 is the
+// opcode and must stay clear.
+const MaxAddress = 1<<15 - 1
+
+// Instruction is one decoded Hack instruction. Value is only meaningful
+// for A-instructions; Comp, Dest and Jump only for C-instructions.
+type Instruction struct {
+	Kind  Kind
+	Value uint16
+	Comp  Comp
+	Dest  Dest
+	Jump  Jump
+}
+
+// A returns the A-instruction @value.
+func A(value uint16) Instruction {
+	return Instruction{Kind: AInstruction, Value: value}
+}
+
+// C returns the C-instruction dest=comp;jump.
+func C(dest Dest, comp Comp, jump Jump) Instruction {
+	return Instruction{Kind: CInstruction, Dest: dest, Comp: comp, Jump: jump}
+}
+
+// Encode returns the 16-bit machine word for the instruction.
+func (i Instruction) Encode() uint16 {
+	if i.Kind == AInstruction {
+		return i.Value & MaxAddress
+	}
+	return 0b111<<13 | uint16(i.Comp)<<6 | uint16(i.Dest)<<3 | uint16(i.Jump)
+}
+
+// String formats the instruction in canonical assembly, leaving out a null
+// dest or jump.
+func (i Instruction) String() string {
+	if i.Kind == AInstruction {
+		return "@" + strconv.Itoa(int(i.Value))
+	}
+	text := i.Comp.String()
+	if i.Dest != DestNull {
+		text = i.Dest.String() + "=" + text
+	}
+	if i.Jump != JumpNull {
+		text += ";" + i.Jump.String()
+	}
+	return text
+}
+
+// DecodeError explains why a word is not a valid instruction. Bit is the
+// 1-based position, counted from the left, where the problem starts.
+type DecodeError struct {
+	Word   uint16
+	Bit    int
+	Reason string
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%016b: %s", e.Word, e.Reason)
+}
+
+// Decode turns a machine word back into an instruction. C-instructions
+// must start with 111 and use one of the 28 defined comp codes.
+func Decode(word uint16) (Instruction, error) {
+	if word&0x8000 == 0 {
+		return A(word), nil
+	}
+	if word>>13 != 0b111 {
+		return Instruction{}, &DecodeError{Word: word, Bit: 2, Reason: "C-instruction does not start with 111"}
+	}
+	comp := Comp(word >> 6 & 0x7F)
+	if _, exist := compNames[comp]; !exist {
+		return Instruction{}, &DecodeError{Word: word, Bit: 4, Reason: fmt.Sprintf("no computation for a/c bits %07b", uint8(comp))}
+	}
+	return C(Dest(word>>3&0x7), comp, Jump(word&0x7)), nil
+}
+
+// Dest is the 3-bit destination field: A, D and M bits from left to right.
+type Dest uint8
+
+const (
+	DestNull Dest = 0b000
+	DestM    Dest = 0b001
+	DestD    Dest = 0b010
+	DestMD   Dest = 0b011
+	DestA    Dest = 0b100
+	DestAM   Dest = 0b101
+	DestAD   Dest = 0b110
+	DestAMD  Dest = 0b111
+)
+
+var destNames = [...]string{"null", "M", "D", "MD", "A", "AM", "AD", "AMD"}
+
+func (d Dest) String() string {
+	return destNames[d&0x7]
+}
+
+// WritesA, WritesD and WritesM report which registers the field stores to.
+func (d Dest) WritesA() bool { return d&DestA != 0 }
+func (d Dest) WritesD() bool { return d&DestD != 0 }
+func (d Dest) WritesM() bool { return d&DestM != 0 }
+
+// ParseDest accepts "null" or the registers A, M and D in any order, each
+// at most once, so MD and DM are the same destination.
+func ParseDest(text string) (Dest, bool) {
+	if text == "null" {
+		return DestNull, true
+	}
+	if text == "" {
+		return DestNull, false
+	}
+	dest := DestNull
+	for _, register := range text {
+		var bit Dest
+		switch register {
+		case 'A':
+			bit = DestA
+		case 'M':
+			bit = DestM
+		case 'D':
+			bit = DestD
+		default:
+			return DestNull, false
+		}
+		if dest&bit != 0 {
+			return DestNull, false
+		}
+		dest |= bit
+	}
+	return dest, true
+}
+
+// Jump is the 3-bit jump field: jump if out < 0, = 0, > 0.
+type Jump uint8
+
+const (
+	JumpNull Jump = iota
+	JumpJGT
+	JumpJEQ
+	JumpJGE
+	JumpJLT
+	JumpJNE
+	JumpJLE
+	JumpJMP
+)
+
+var jumpNames = [...]string{"null", "JGT", "JEQ", "JGE", "JLT", "JNE", "JLE", "JMP"}
+
+func (j Jump) String() string {
+	return jumpNames[j&0x7]
+}
+
+func ParseJump(text string) (Jump, bool) {
+	for jump, name := range jumpNames {
+		if name == text {
+			return Jump(jump), true
+		}
+	}
+	return JumpNull, false
+}
+
+// Taken reports whether the jump fires for an ALU output.
+func (j Jump) Taken(out int16) bool {
+	return j&JumpJLT != 0 && out < 0 || j&JumpJEQ != 0 && out == 0 || j&JumpJGT != 0 && out > 0
+}
+
+// Comp is the 7-bit computation field: the a bit followed by the ALU
+// control bits zx, nx, zy, ny, f and no.
+type Comp uint8
+
+const (
+	CompZero     Comp = 0b0101010
+	CompOne      Comp = 0b0111111
+	CompMinusOne Comp = 0b0111010
+	CompD        Comp = 0b0001100
+	CompA        Comp = 0b0110000
+	CompM        Comp = 0b1110000
+	CompNotD     Comp = 0b0001101
+	CompNotA     Comp = 0b0110001
+	CompNotM     Comp = 0b1110001
+	CompNegD     Comp = 0b0001111
+	CompNegA     Comp = 0b0110011
+	CompNegM     Comp = 0b1110011
+	CompDPlus1   Comp = 0b0011111
+	CompAPlus1   Comp = 0b0110111
+	CompMPlus1   Comp = 0b1110111
+	CompDMinus1  Comp = 0b0001110
+	CompAMinus1  Comp = 0b0110010
+	CompMMinus1  Comp = 0b1110010
+	CompDPlusA   Comp = 0b0000010
+	CompDPlusM   Comp = 0b1000010
+	CompDMinusA  Comp = 0b0010011
+	CompDMinusM  Comp = 0b1010011
+	CompAMinusD  Comp = 0b0000111
+	CompMMinusD  Comp = 0b1000111
+	CompDAndA    Comp = 0b0000000
+	CompDAndM    Comp = 0b1000000
+	CompDOrA     Comp = 0b0010101
+	CompDOrM     Comp = 0b1010101
+)
+
+var compNames = map[Comp]string{
+	CompZero:     "0",
+	CompOne:      "1",
+	CompMinusOne: "-1",
+	CompD:        "D",
+	CompA:        "A",
+	CompM:        "M",
+	CompNotD:     "!D",
+	CompNotA:     "!A",
+	CompNotM:     "!M",
+	CompNegD:     "-D",
+	CompNegA:     "-A",
+	CompNegM:     "-M",
+	CompDPlus1:   "D+1",
+	CompAPlus1:   "A+1",
+	CompMPlus1:   "M+1",
+	CompDMinus1:  "D-1",
+	CompAMinus1:  "A-1",
+	CompMMinus1:  "M-1",
+	CompDPlusA:   "D+A",
+	CompDPlusM:   "D+M",
+	CompDMinusA:  "D-A",
+	CompDMinusM:  "D-M",
+	CompAMinusD:  "A-D",
+	CompMMinusD:  "M-D",
+	CompDAndA:    "D&A",
+	CompDAndM:    "D&M",
+	CompDOrA:     "D|A",
+	CompDOrM:     "D|M",
+}
+
+var compsByName = func() map[string]Comp {
+	comps := make(map[string]Comp, len(compNames))
+	for comp, name := range compNames {
+		comps[name] = comp
+	}
+	return comps
+}()
+
+// Comps lists the 28 defined computations.
+func Comps() []Comp {
+	comps := make([]Comp, 0, len(compNames))
+	for comp := range compNames {
+		comps = append(comps, comp)
+	}
+	return comps
+}
+
+func (c Comp) String() string {
+	if name, exist := compNames[c]; exist {
+		return name
+	}
+	return fmt.Sprintf("Comp(%07b)", uint8(c))
+}
+
+// UsesM reports whether the computation reads memory instead of A.
+func (c Comp) UsesM() bool {
+	return c&0b1000000 != 0
+}
+
+// ParseComp accepts the mnemonics of the Hack specification, plus the
+// swapped operand order of commutative operations such as A+D, M&D or 1+D.
+// Blanks are not allowed.
+func ParseComp(text string) (Comp, bool) {
+	if comp, exist := compsByName[text]; exist {
+		return comp, true
+	}
+	if len(text) == 3 && strings.ContainsRune("+&|", rune(text[1])) {
+		comp, exist := compsByName[string(text[2])+string(text[1])+string(text[0])]
+		return comp, exist
+	}
+	return 0, false
+}
+
+// Compute runs the ALU for the computation on the given D register and the
+// y operand, which is A or M depending on the a bit.
+func (c Comp) Compute(d uint16, y uint16) uint16 {
+	x := d
+	if c&0b0100000 != 0 { // zx
+		x = 0
+	}
+	if c&0b0010000 != 0 { // nx
+		x = ^x
+	}
+	if c&0b0001000 != 0 { // zy
+		y = 0
+	}
+	if c&0b0000100 != 0 { // ny
+		y = ^y
+	}
+	out := x & y
+	if c&0b0000010 != 0 { // f
+		out = x + y
+	}
+	if c&0b0000001 != 0 { // no
+		out = ^out
+	}
+	return out
+}
+
+// BadSymbolChar checks a name against the Hack assembly symbol grammar:
+// letters, digits, '_', '.', '$' and ':', not starting with a digit. It
+// returns the index of the first offending character, or -1 when the name
+// is legal.
+func BadSymbolChar(name string) int {
+	for index, char := range name {
+		switch {
+		case char >= 'a' && char <= 'z', char >= 'A' && char <= 'Z', strings.ContainsRune("_.$:", char):
+		case char >= '0' && char <= '9' && index > 0:
+		default:
+			return index
+		}
+	}
+	return -1
+}
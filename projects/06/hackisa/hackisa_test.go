@@ -0,0 +1,222 @@
+package hackisa
+
+import (
+	"errors"
+	"testing"
+)
+
+// comps is the comp table of the Hack specification, written out by hand
+// so the package's own table is checked against it.
+var comps = []struct {
+	name string
+	bits uint16 // a c1..c6
+}{
+	{"0", 0b0101010},
+	{"1", 0b0111111},
+	{"-1", 0b0111010},
+	{"D", 0b0001100},
+	{"A", 0b0110000},
+	{"!D", 0b0001101},
+	{"!A", 0b0110001},
+	{"-D", 0b0001111},
+	{"-A", 0b0110011},
+	{"D+1", 0b0011111},
+	{"A+1", 0b0110111},
+	{"D-1", 0b0001110},
+	{"A-1", 0b0110010},
+	{"D+A", 0b0000010},
+	{"D-A", 0b0010011},
+	{"A-D", 0b0000111},
+	{"D&A", 0b0000000},
+	{"D|A", 0b0010101},
+	{"M", 0b1110000},
+	{"!M", 0b1110001},
+	{"-M", 0b1110011},
+	{"M+1", 0b1110111},
+	{"M-1", 0b1110010},
+	{"D+M", 0b1000010},
+	{"D-M", 0b1010011},
+	{"M-D", 0b1000111},
+	{"D&M", 0b1000000},
+	{"D|M", 0b1010101},
+}
+
+func TestComps(t *testing.T) {
+	if len(comps) != 28 || len(Comps()) != 28 {
+		t.Fatalf("want 28 comps, have %d in the test table and %d in Comps", len(comps), len(Comps()))
+	}
+	for _, test := range comps {
+		t.Run(test.name, func(t *testing.T) {
+			comp, ok := ParseComp(test.name)
+			if !ok {
+				t.Fatalf("ParseComp(%q) failed", test.name)
+			}
+			if uint16(comp) != test.bits {
+				t.Errorf("ParseComp(%q) = %07b, want %07b", test.name, uint8(comp), test.bits)
+			}
+			word := C(DestNull, comp, JumpNull).Encode()
+			if want := 0b111<<13 | test.bits<<6; word != want {
+				t.Errorf("Encode = %016b, want %016b", word, want)
+			}
+			instruction, err := Decode(word)
+			if err != nil {
+				t.Fatalf("Decode(%016b): %v", word, err)
+			}
+			if got := instruction.String(); got != test.name {
+				t.Errorf("String = %q, want %q", got, test.name)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, comp := range Comps() {
+		for dest := DestNull; dest <= DestAMD; dest++ {
+			for jump := JumpNull; jump <= JumpJMP; jump++ {
+				instruction := C(dest, comp, jump)
+				decoded, err := Decode(instruction.Encode())
+				if err != nil {
+					t.Fatalf("Decode(%v): %v", instruction, err)
+				}
+				if decoded != instruction {
+					t.Errorf("Decode(Encode(%v)) = %v", instruction, decoded)
+				}
+			}
+		}
+	}
+	for _, value := range []uint16{0, 1, 16384, MaxAddress} {
+		decoded, err := Decode(A(value).Encode())
+		if err != nil || decoded != A(value) {
+			t.Errorf("Decode(Encode(@%d)) = %v, %v", value, decoded, err)
+		}
+	}
+}
+
+func TestParseDest(t *testing.T) {
+	tests := []struct {
+		text string
+		dest Dest
+		ok   bool
+	}{
+		{"null", DestNull, true},
+		{"M", DestM, true},
+		{"D", DestD, true},
+		{"A", DestA, true},
+		{"MD", DestMD, true},
+		{"DM", DestMD, true},
+		{"AM", DestAM, true},
+		{"MA", DestAM, true},
+		{"AD", DestAD, true},
+		{"DA", DestAD, true},
+		{"AMD", DestAMD, true},
+		{"ADM", DestAMD, true},
+		{"MAD", DestAMD, true},
+		{"MDA", DestAMD, true},
+		{"DAM", DestAMD, true},
+		{"DMA", DestAMD, true},
+		{"", DestNull, false},
+		{"MM", DestNull, false},
+		{"AMDA", DestNull, false},
+		{"X", DestNull, false},
+		{"m", DestNull, false},
+	}
+	for _, test := range tests {
+		dest, ok := ParseDest(test.text)
+		if ok != test.ok || dest != test.dest {
+			t.Errorf("ParseDest(%q) = %v, %v, want %v, %v", test.text, dest, ok, test.dest, test.ok)
+		}
+	}
+}
+
+func TestParseJump(t *testing.T) {
+	names := []string{"null", "JGT", "JEQ", "JGE", "JLT", "JNE", "JLE", "JMP"}
+	for bits, name := range names {
+		jump, ok := ParseJump(name)
+		if !ok || jump != Jump(bits) {
+			t.Errorf("ParseJump(%q) = %v, %v, want %03b", name, jump, ok, bits)
+		}
+		if jump.String() != name {
+			t.Errorf("Jump(%03b).String() = %q, want %q", bits, jump.String(), name)
+		}
+	}
+	for _, text := range []string{"", "jmp", "JMPX", "JNZ"} {
+		if _, ok := ParseJump(text); ok {
+			t.Errorf("ParseJump(%q) succeeded", text)
+		}
+	}
+}
+
+func TestParseCommutativeComp(t *testing.T) {
+	tests := []struct {
+		text string
+		comp Comp
+	}{
+		{"A+D", CompDPlusA},
+		{"M+D", CompDPlusM},
+		{"A&D", CompDAndA},
+		{"M&D", CompDAndM},
+		{"A|D", CompDOrA},
+		{"M|D", CompDOrM},
+		{"1+D", CompDPlus1},
+		{"1+A", CompAPlus1},
+		{"1+M", CompMPlus1},
+	}
+	for _, test := range tests {
+		comp, ok := ParseComp(test.text)
+		if !ok || comp != test.comp {
+			t.Errorf("ParseComp(%q) = %v, %v, want %v", test.text, comp, ok, test.comp)
+		}
+	}
+	// Subtraction is not commutative, and blanks are not allowed.
+	for _, text := range []string{"A-D+", "1-D", "A-M", "D + A", "D+D", ""} {
+		if _, ok := ParseComp(text); ok {
+			t.Errorf("ParseComp(%q) succeeded", text)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		word uint16
+		bit  int
+	}{
+		{0b1000000000000000, 2},
+		{0b1010110000010000, 2},
+		{0b1100110000010000, 2},
+		{0b1110000001000000, 4}, // a=0 c=000001
+		{0b1111111111000000, 4}, // a=1 c=111111
+		{0b1111101010000000, 4}, // a=1 c=101010, no "M" version of 0
+	}
+	for _, test := range tests {
+		_, err := Decode(test.word)
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Errorf("Decode(%016b) = %v, want a DecodeError", test.word, err)
+			continue
+		}
+		if decodeErr.Word != test.word || decodeErr.Bit != test.bit {
+			t.Errorf("Decode(%016b) error at bit %d of %016b, want bit %d", test.word, decodeErr.Bit, decodeErr.Word, test.bit)
+		}
+	}
+}
+
+func TestBadSymbolChar(t *testing.T) {
+	tests := []struct {
+		name string
+		bad  int
+	}{
+		{"LOOP", -1},
+		{"Main.main$if_true:1", -1},
+		{"_x9", -1},
+		{"", -1},
+		{"1loop", 0},
+		{"a-b", 1},
+		{"a b", 1},
+		{"é", 0},
+	}
+	for _, test := range tests {
+		if got := BadSymbolChar(test.name); got != test.bad {
+			t.Errorf("BadSymbolChar(%q) = %d, want %d", test.name, got, test.bad)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package tstscript
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// column is one output-list entry such as RAM[256]%D2.6.2: the variable,
+// its format (D decimal, X hex, B binary, S string) and the left padding,
+// value width and right padding of its cell.
+type column struct {
+	name  string
+	kind  byte
+	left  int
+	width int
+	right int
+}
+
+func parseColumn(spec string) (column, error) {
+	index := strings.LastIndex(spec, "%")
+	if index == -1 {
+		return column{name: spec, kind: 'D', left: 1, width: 6, right: 1}, nil
+	}
+	format := spec[index+1:]
+	sizes := strings.Split(format[min(1, len(format)):], ".")
+	if len(format) < 2 || !strings.ContainsRune("DXBS", rune(format[0])) || len(sizes) != 3 {
+		return column{}, fmt.Errorf("bad output format %q, expected name%%D1.6.1", spec)
+	}
+	numbers := [3]int{}
+	for i, size := range sizes {
+		number, err := strconv.Atoi(size)
+		if err != nil || number < 0 {
+			return column{}, fmt.Errorf("bad output format %q, expected name%%D1.6.1", spec)
+		}
+		numbers[i] = number
+	}
+	return column{name: spec[:index], kind: format[0], left: numbers[0], width: numbers[1], right: numbers[2]}, nil
+}
+
+// header centres the variable name in the cell, cutting it if it does not
+// fit.
+func (c column) header() string {
+	size := c.left + c.width + c.right
+	name := c.name
+	if len(name) > size {
+		name = name[:size]
+	}
+	left := (size - len(name)) / 2
+	return strings.Repeat(" ", left) + name + strings.Repeat(" ", size-left-len(name))
+}
+
+// format renders a value right-aligned in its cell, or left-aligned for
+// the S format. Hex and binary show the low bits, zero-padded.
+func (c column) format(value int) string {
+	var text string
+	switch c.kind {
+	case 'X':
+		text = lastDigits(fmt.Sprintf("%0*X", c.width, uint16(value)), c.width)
+	case 'B':
+		text = lastDigits(fmt.Sprintf("%0*b", c.width, uint16(value)), c.width)
+	case 'S':
+		text = fmt.Sprintf("%-*d", c.width, value)
+	default:
+		text = fmt.Sprintf("%*d", c.width, value)
+	}
+	return strings.Repeat(" ", c.left) + text + strings.Repeat(" ", c.right)
+}
+
+func lastDigits(text string, width int) string {
+	return text[len(text)-width:]
+}
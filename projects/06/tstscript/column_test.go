@@ -0,0 +1,44 @@
+package tstscript
+
+import "testing"
+
+// The expected cells are cut from the course's .cmp files.
+func TestColumn(t *testing.T) {
+	tests := []struct {
+		spec   string
+		value  int
+		header string
+		cell   string
+	}{
+		{"RAM[0]%D2.6.2", 3, "  RAM[0]  ", "       3  "},                    // 04/mult/Mult.cmp
+		{"RAM[16384]%D2.6.2", -1, "RAM[16384]", "      -1  "},               // 04/fill/FillAutomatic.cmp
+		{"in%D1.6.1", 12345, "   in   ", "  12345 "},                        // 05/Memory.cmp
+		{"load%B2.1.2", 1, "load ", "  1  "},                                // 05/Memory.cmp
+		{"address%B1.15.1", 8192, "     address     ", " 010000000000000 "}, // 05/Memory.cmp
+		{"x%B1.16.1", -1, "        x         ", " 1111111111111111 "},       // 02/ALU-nostat.cmp
+		{"time%S1.4.1", 1, " time ", " 1    "},                              // 03/b/RAM4K.cmp
+		{"A%X1.4.1", -1, "  A   ", " FFFF "},
+		{"RAM[0]", 42, " RAM[0] ", "     42 "},
+	}
+	for _, test := range tests {
+		c, err := parseColumn(test.spec)
+		if err != nil {
+			t.Errorf("parseColumn(%q): %v", test.spec, err)
+			continue
+		}
+		if got := c.header(); got != test.header {
+			t.Errorf("%s header = %q, want %q", test.spec, got, test.header)
+		}
+		if got := c.format(test.value); got != test.cell {
+			t.Errorf("%s format(%d) = %q, want %q", test.spec, test.value, got, test.cell)
+		}
+	}
+}
+
+func TestBadColumn(t *testing.T) {
+	for _, spec := range []string{"RAM[0]%D2.6", "RAM[0]%Q1.6.1", "RAM[0]%D1.x.1", "RAM[0]%", "RAM[0]%D-1.6.1"} {
+		if _, err := parseColumn(spec); err == nil {
+			t.Errorf("parseColumn(%q) succeeded", spec)
+		}
+	}
+}
@@ -0,0 +1,405 @@
+package tstscript
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/MinhNHHH/nand2tetris/projects/06/hackasm"
+	"github.com/MinhNHHH/nand2tetris/projects/06/hackcpu"
+)
+
+// Options controls a single script run.
+type Options struct {
+	// Dir is where load, output-file and compare-to look for files. It
+	// defaults to the directory of the script for RunFile and to the
+	// working directory for Run.
+	Dir string
+	// Echo receives the text of echo commands; nil discards it.
+	Echo io.Writer
+}
+
+// Result is what a script produced: the rows written by output commands,
+// including the headers written by output-list, and the first row that
+// differed from the compare file, if any.
+type Result struct {
+	OutputFile  string
+	CompareFile string
+	Output      []string
+	Mismatch    *Mismatch
+}
+
+// Mismatch is the first output row that does not match the compare file.
+// Line is 1-based in both files.
+type Mismatch struct {
+	File string
+	Line int
+	Want string
+	Got  string
+}
+
+func (m *Mismatch) Error() string {
+	return fmt.Sprintf("%s:%d: comparison failure\n  want: %s\n  got:  %s", m.File, m.Line, m.Want, m.Got)
+}
+
+// RunFile parses and runs the script at path.
+func RunFile(path string, options Options) (*Result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	script, err := Parse(file, path)
+	if err != nil {
+		return nil, err
+	}
+	if options.Dir == "" {
+		options.Dir = filepath.Dir(path)
+	}
+	return Run(script, options)
+}
+
+// Run executes the script on a fresh Hack computer. It stops at the first
+// output row that differs from the compare file, like the CPU emulator,
+// and writes the output file, if the script names one, either way.
+func Run(script *Script, options Options) (*Result, error) {
+	r := &runner{script: script, options: options, computer: hackcpu.New(), result: &Result{}}
+	err := r.run(script.Commands)
+	if err == errStopped {
+		err = nil
+	}
+	if r.result.OutputFile != "" {
+		content := strings.Join(r.result.Output, "\n")
+		if len(r.result.Output) > 0 {
+			content += "\n"
+		}
+		if writeErr := os.WriteFile(r.path(r.result.OutputFile), []byte(content), 0644); writeErr != nil && err == nil {
+			err = writeErr
+		}
+	}
+	return r.result, err
+}
+
+// CopyDir copies the files directly in from into the directory to,
+// creating it if needed. Running a course script on the copy keeps the
+// files it loads and writes out of the source tree.
+func CopyDir(from string, to string) error {
+	if err := os.MkdirAll(to, 0755); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(from)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(from, entry.Name()))
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(to, entry.Name()), content, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errStopped ends the run after a comparison failure.
+var errStopped = fmt.Errorf("stopped")
+
+type runner struct {
+	script   *Script
+	options  Options
+	computer *hackcpu.Computer
+	columns  []column
+	compare  []string
+	result   *Result
+}
+
+func (r *runner) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(r.options.Dir, name)
+}
+
+func (r *runner) errorf(command Command, format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", r.script.Name, command.Line, fmt.Sprintf(format, args...))
+}
+
+func (r *runner) run(commands []Command) error {
+	for _, command := range commands {
+		if err := r.execute(command); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *runner) execute(command Command) error {
+	words := command.Words
+	arguments := len(words) - 1
+	switch words[0] {
+	case "repeat":
+		if arguments != 1 {
+			return r.errorf(command, "repeat without a count is not supported")
+		}
+		count, err := strconv.Atoi(words[1])
+		if err != nil || count < 0 {
+			return r.errorf(command, "bad repeat count %q", words[1])
+		}
+		for i := 0; i < count; i++ {
+			if err := r.run(command.Body); err != nil {
+				return err
+			}
+		}
+	case "load":
+		if arguments != 1 {
+			return r.errorf(command, "expected load <file.asm|file.hack>")
+		}
+		if err := r.load(words[1]); err != nil {
+			return r.errorf(command, "%v", err)
+		}
+	case "output-file":
+		if arguments != 1 {
+			return r.errorf(command, "expected output-file <file>")
+		}
+		r.result.OutputFile = words[1]
+	case "compare-to":
+		if arguments != 1 {
+			return r.errorf(command, "expected compare-to <file>")
+		}
+		lines, err := readCompareFile(r.path(words[1]))
+		if err != nil {
+			return r.errorf(command, "%v", err)
+		}
+		r.result.CompareFile, r.compare = words[1], lines
+	case "output-list":
+		r.columns = r.columns[:0]
+		for _, spec := range words[1:] {
+			column, err := parseColumn(spec)
+			if err != nil {
+				return r.errorf(command, "%v", err)
+			}
+			r.columns = append(r.columns, column)
+		}
+		return r.output(r.header())
+	case "output":
+		row, err := r.row()
+		if err != nil {
+			return r.errorf(command, "%v", err)
+		}
+		return r.output(row)
+	case "set":
+		if arguments != 2 {
+			return r.errorf(command, "expected set <variable> <value>")
+		}
+		value, err := parseValue(words[2])
+		if err != nil {
+			return r.errorf(command, "%v", err)
+		}
+		if err := r.set(words[1], value); err != nil {
+			return r.errorf(command, "%v", err)
+		}
+	case "ticktock", "tock":
+		if err := r.computer.Step(); err != nil {
+			return r.errorf(command, "%v", err)
+		}
+	case "tick", "clear-echo":
+		// A tick alone does not complete a cycle; the CPU advances on tock.
+	case "echo":
+		if r.options.Echo != nil {
+			fmt.Fprintln(r.options.Echo, strings.Join(words[1:], " "))
+		}
+	default:
+		return r.errorf(command, "unsupported command %q", words[0])
+	}
+	return nil
+}
+
+// load puts a program into ROM, assembling it first if it is source.
+func (r *runner) load(name string) error {
+	file, err := os.Open(r.path(name))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if !strings.EqualFold(filepath.Ext(name), ".asm") {
+		return r.computer.LoadROM(file)
+	}
+
+	var binary bytes.Buffer
+	diagnostics, err := hackasm.Assemble(file, &binary, hackasm.Options{
+		FileName: r.path(name),
+		Open: func(include string) (io.ReadCloser, error) {
+			return os.Open(include)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == hackasm.SeverityError {
+			return fmt.Errorf("%v", diagnostic)
+		}
+	}
+	return r.computer.LoadROM(&binary)
+}
+
+// output writes a row and checks it against the compare file.
+func (r *runner) output(row string) error {
+	r.result.Output = append(r.result.Output, row)
+	line := len(r.result.Output)
+	if r.compare == nil {
+		return nil
+	}
+	want := ""
+	if line <= len(r.compare) {
+		want = r.compare[line-1]
+	}
+	if !matches(row, want) {
+		r.result.Mismatch = &Mismatch{File: r.result.CompareFile, Line: line, Want: want, Got: row}
+		return errStopped
+	}
+	return nil
+}
+
+// matches compares an output row to a compare file row, where '*' in the
+// compare file matches any character.
+func matches(row string, want string) bool {
+	if len(row) != len(want) {
+		return false
+	}
+	for i := 0; i < len(row); i++ {
+		if want[i] != '*' && want[i] != row[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func readCompareFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	lines := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	return lines, scanner.Err()
+}
+
+func (r *runner) header() string {
+	var header strings.Builder
+	header.WriteString("|")
+	for _, column := range r.columns {
+		header.WriteString(column.header())
+		header.WriteString("|")
+	}
+	return header.String()
+}
+
+func (r *runner) row() (string, error) {
+	var row strings.Builder
+	row.WriteString("|")
+	for _, column := range r.columns {
+		value, err := r.get(column.name)
+		if err != nil {
+			return "", err
+		}
+		row.WriteString(column.format(value))
+		row.WriteString("|")
+	}
+	return row.String(), nil
+}
+
+// get reads a script variable: A, D, PC, time, RAM[i] or ROM[i]. Registers
+// and memory words are signed, as the D format prints them.
+func (r *runner) get(name string) (int, error) {
+	switch name {
+	case "A":
+		return int(int16(r.computer.A)), nil
+	case "D":
+		return int(int16(r.computer.D)), nil
+	case "PC":
+		return int(r.computer.PC), nil
+	case "time":
+		return r.computer.Cycles, nil
+	}
+	memory, address, err := r.memory(name)
+	if err != nil {
+		return 0, err
+	}
+	return int(int16(memory[address])), nil
+}
+
+func (r *runner) set(name string, value uint16) error {
+	switch name {
+	case "A":
+		r.computer.A = value
+		return nil
+	case "D":
+		r.computer.D = value
+		return nil
+	case "PC":
+		r.computer.PC = value
+		return nil
+	}
+	memory, address, err := r.memory(name)
+	if err != nil {
+		return err
+	}
+	memory[address] = value
+	return nil
+}
+
+// memory resolves RAM[i] and ROM[i] to the array and index they name.
+func (r *runner) memory(name string) ([]uint16, int, error) {
+	var memory []uint16
+	switch {
+	case strings.HasPrefix(name, "RAM[") && strings.HasSuffix(name, "]"):
+		memory = r.computer.RAM[:]
+	case strings.HasPrefix(name, "ROM[") && strings.HasSuffix(name, "]"):
+		memory = r.computer.ROM[:]
+	default:
+		return nil, 0, fmt.Errorf("unknown variable %q", name)
+	}
+	address, err := strconv.Atoi(name[4 : len(name)-1])
+	if err != nil || address < 0 || address >= len(memory) {
+		return nil, 0, fmt.Errorf("bad address in %q", name)
+	}
+	return memory, address, nil
+}
+
+// parseValue reads a set value: decimal, or %D, %X or %B followed by
+// decimal, hex or binary digits.
+func parseValue(text string) (uint16, error) {
+	digits, base := text, 10
+	if len(text) > 2 && text[0] == '%' {
+		switch text[1] {
+		case 'D':
+			digits = text[2:]
+		case 'X':
+			digits, base = text[2:], 16
+		case 'B':
+			digits, base = text[2:], 2
+		default:
+			return 0, fmt.Errorf("bad value %q", text)
+		}
+	}
+	value, err := strconv.ParseInt(digits, base, 32)
+	if err != nil || value < -32768 || value > 65535 {
+		return 0, fmt.Errorf("bad value %q", text)
+	}
+	return uint16(value), nil
+}
@@ -0,0 +1,79 @@
+package tstscript
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCourseScripts(t *testing.T) {
+	tests := []struct {
+		dir    string
+		script string
+	}{
+		{"../../04/mult", "Mult.tst"},
+		{"../../04/fill", "FillAutomatic.tst"},
+	}
+	for _, test := range tests {
+		t.Run(test.script, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := CopyDir(test.dir, dir); err != nil {
+				t.Fatal(err)
+			}
+			result, err := RunFile(filepath.Join(dir, test.script), Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Mismatch != nil {
+				t.Fatal(result.Mismatch)
+			}
+			want, err := os.ReadFile(filepath.Join(dir, result.CompareFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(filepath.Join(dir, result.OutputFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The course's .cmp files may use CRLF or omit the last newline.
+			if strings.TrimRight(strings.ReplaceAll(string(want), "\r", ""), "\n") != strings.TrimRight(string(got), "\n") {
+				t.Errorf("%s differs from %s:\n%s", result.OutputFile, result.CompareFile, got)
+			}
+		})
+	}
+}
+
+func TestMismatch(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"Sum.asm": "@R0\nD=M\n@R1\nD=D+M\n@R2\nM=D\n",
+		"Sum.cmp": "|  RAM[2]  |\n|       5  |\n|       6  |\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	script, err := Parse(strings.NewReader(`
+load Sum.asm, compare-to Sum.cmp, output-list RAM[2]%D2.6.2;
+set RAM[0] 2, set RAM[1] 3; repeat 6 { ticktock; } output;
+set PC 0, set RAM[1] 5; repeat 6 { ticktock; } output;
+set PC 0; repeat 6 { ticktock; } output;
+`), "Sum.tst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Run(script, Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Mismatch{File: "Sum.cmp", Line: 3, Want: "|       6  |", Got: "|       7  |"}
+	if result.Mismatch == nil || *result.Mismatch != want {
+		t.Fatalf("Mismatch = %+v, want %+v", result.Mismatch, want)
+	}
+	// The run stops at the first mismatch.
+	if len(result.Output) != 3 {
+		t.Errorf("got %d output rows, want 3", len(result.Output))
+	}
+}
@@ -0,0 +1,158 @@
+// Package tstscript runs nand2tetris CPU emulator test scripts (.tst) on
+// the Go Hack emulator, writes the .out file and compares it to the .cmp
+// file the way the course's CPUEmulator does.
+package tstscript
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Command is one script command such as `set RAM[0] 256` or a repeat
+// block. Words are the command and its arguments; Body holds the commands
+// inside `repeat N { ... }`.
+type Command struct {
+	Line  int
+	Words []string
+	Body  []Command
+}
+
+// Script is a parsed .tst file.
+type Script struct {
+	Name     string
+	Commands []Command
+}
+
+// token is a word, a quoted string or one of the punctuation marks
+// , ; { } that structure the script.
+type token struct {
+	text   string
+	line   int
+	quoted bool
+}
+
+// Parse reads a test script. Commands end with ',' or ';' and repeat
+// blocks are enclosed in braces; // and /* */ comments are ignored.
+func Parse(r io.Reader, name string) (*Script, error) {
+	tokens, err := tokenize(r, name)
+	if err != nil {
+		return nil, err
+	}
+	parser := scriptParser{name: name, tokens: tokens}
+	commands, err := parser.commands(false)
+	if err != nil {
+		return nil, err
+	}
+	return &Script{Name: name, Commands: commands}, nil
+}
+
+func tokenize(r io.Reader, name string) ([]token, error) {
+	tokens := []token{}
+	inComment := false
+	scanner := bufio.NewScanner(r)
+	for row := 1; scanner.Scan(); row++ {
+		line := scanner.Text()
+		for i := 0; i < len(line); {
+			switch {
+			case inComment:
+				end := strings.Index(line[i:], "*/")
+				if end == -1 {
+					i = len(line)
+					continue
+				}
+				i += end + 2
+				inComment = false
+			case strings.HasPrefix(line[i:], "//"):
+				i = len(line)
+			case strings.HasPrefix(line[i:], "/*"):
+				i += 2
+				inComment = true
+			case line[i] == ' ' || line[i] == '\t' || line[i] == '\r':
+				i++
+			case strings.ContainsRune(",;{}", rune(line[i])):
+				tokens = append(tokens, token{text: line[i : i+1], line: row})
+				i++
+			case line[i] == '"':
+				end := strings.IndexByte(line[i+1:], '"')
+				if end == -1 {
+					return nil, fmt.Errorf("%s:%d: unterminated string", name, row)
+				}
+				tokens = append(tokens, token{text: line[i+1 : i+1+end], line: row, quoted: true})
+				i += end + 2
+			default:
+				start := i
+				for i < len(line) && !strings.ContainsRune(" \t\r,;{}\"", rune(line[i])) && !strings.HasPrefix(line[i:], "//") {
+					i++
+				}
+				tokens = append(tokens, token{text: line[start:i], line: row})
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+type scriptParser struct {
+	name     string
+	tokens   []token
+	position int
+}
+
+// commands reads commands up to the end of the script, or up to the
+// closing brace when inBlock is set.
+func (p *scriptParser) commands(inBlock bool) ([]Command, error) {
+	commands := []Command{}
+	words, line := []string{}, 0
+	for p.position < len(p.tokens) {
+		current := p.tokens[p.position]
+		p.position++
+		if current.quoted {
+			if line == 0 {
+				line = current.line
+			}
+			words = append(words, current.text)
+			continue
+		}
+		switch current.text {
+		case ",", ";":
+			if len(words) > 0 {
+				commands = append(commands, Command{Line: line, Words: words})
+			}
+			words, line = []string{}, 0
+		case "{":
+			if len(words) == 0 || (words[0] != "repeat" && words[0] != "while") {
+				return nil, fmt.Errorf("%s:%d: unexpected '{'", p.name, current.line)
+			}
+			body, err := p.commands(true)
+			if err != nil {
+				return nil, err
+			}
+			commands = append(commands, Command{Line: line, Words: words, Body: body})
+			words, line = []string{}, 0
+		case "}":
+			if !inBlock {
+				return nil, fmt.Errorf("%s:%d: unexpected '}'", p.name, current.line)
+			}
+			if len(words) > 0 {
+				commands = append(commands, Command{Line: line, Words: words})
+			}
+			return commands, nil
+		default:
+			if line == 0 {
+				line = current.line
+			}
+			words = append(words, current.text)
+		}
+	}
+	if inBlock {
+		return nil, fmt.Errorf("%s: missing '}' at end of script", p.name)
+	}
+	if len(words) > 0 {
+		commands = append(commands, Command{Line: line, Words: words})
+	}
+	return commands, nil
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
@@ -26,6 +28,7 @@ const (
 )
 
 type Parser struct {
+	fileName       string
 	lines          []string
 	arg1           ArgType
 	arg2           ArgType
@@ -33,14 +36,19 @@ type Parser struct {
 	currentRow     int
 }
 
+// Writer collects the translated program in memory so nothing is written
+// to disk unless the whole translation succeeds.
 type Writer struct {
-	writer *os.File
+	writer *bytes.Buffer
 }
 
 type CodeWriter struct {
 	parser *Parser
 	writer *Writer
 	label  int
+	// fileName is the base name of the .vm file being translated; static
+	// variables are emitted as fileName.index so each file has its own.
+	fileName string
 }
 
 var arithmetic = []string{"add", "sub", "neg", "eq", "gt", "lt", "and", "or", "not"}
@@ -59,6 +67,7 @@ func NewParser(filePath string) (*Parser, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
 	lines := []string{}
@@ -67,7 +76,8 @@ func NewParser(filePath string) (*Parser, error) {
 	}
 
 	return &Parser{
-		lines: lines,
+		fileName: filePath,
+		lines:    lines,
 	}, nil
 }
 
@@ -82,7 +92,7 @@ func (p *Parser) getArg1() ArgType {
 	if p.currentCommand == "" {
 		return ""
 	}
-	splitText := strings.Split(p.currentCommand, " ")
+	splitText := strings.Fields(p.currentCommand)
 	if p.commandType(splitText[0]) == C_RETURN {
 		return ""
 	}
@@ -93,7 +103,7 @@ func (p *Parser) getArg2() ArgType {
 	if p.currentCommand == "" {
 		return ""
 	}
-	splitText := strings.Split(p.currentCommand, " ")
+	splitText := strings.Fields(p.currentCommand)
 	if len(splitText) < 2 {
 		return ""
 	}
@@ -109,7 +119,7 @@ func (p *Parser) commandType(arg string) CommandType {
 		return C_PUSH
 	} else if arg == "goto" {
 		return C_GOTO
-	} else if arg == "if" {
+	} else if arg == "if-goto" {
 		return C_IF
 	} else if arg == "function" {
 		return C_FUNCTION
@@ -126,36 +136,36 @@ func (p *Parser) commandType(arg string) CommandType {
 func (p *Parser) advance() {
 	trimmedLine := strings.TrimSpace(string(p.lines[p.currentRow]))
 
-	if strings.HasPrefix(trimmedLine, "//") || trimmedLine == "" {
-		p.currentRow += 1
-		return
-	}
-
 	if index := strings.Index(trimmedLine, "//"); index != -1 {
-		p.currentCommand = trimmedLine[:index]
-	} else {
-		p.currentCommand = trimmedLine
+		trimmedLine = strings.TrimSpace(trimmedLine[:index])
 	}
+	// Blank and comment-only lines clear the command so it is not
+	// translated twice.
+	p.currentCommand = trimmedLine
 	p.arg1 = p.getArg1()
-	// p.arg2 = p.getArg2()
+	p.arg2 = p.getArg2()
 	p.currentRow += 1
 }
 
+// arguments returns the words of the current command, the command itself
+// first.
+func (p *Parser) arguments() []string {
+	return strings.Fields(p.currentCommand)
+}
+
 func (p *Parser) reset() {
 	p.currentRow = 0
 	p.currentCommand = ""
 }
 
-func createFile(fileName string) (*Writer, error) {
-	f, err := os.Create(fileName)
-	if err != nil {
-		return nil, err
-	}
-
+func newWriter() *Writer {
 	return &Writer{
-		writer: f,
-	}, nil
+		writer: &bytes.Buffer{},
+	}
+}
 
+func (w *Writer) save(fileName string) error {
+	return os.WriteFile(fileName, w.writer.Bytes(), 0644)
 }
 
 func NewCodeWriter(parse *Parser, writer *Writer) *CodeWriter {
@@ -165,6 +175,11 @@ func NewCodeWriter(parse *Parser, writer *Writer) *CodeWriter {
 	}
 }
 
+// setFileName starts translating a new .vm file.
+func (c *CodeWriter) setFileName(filePath string) {
+	c.fileName = strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+}
+
 func (c *CodeWriter) add() ASMType {
 	translator := ""
 	translator += "@SP\n"    // Sets the address of the stack pointer (SP) to the A-register.
@@ -202,8 +217,8 @@ func (c *CodeWriter) neg() ASMType {
 func (c *CodeWriter) not() ASMType {
 	translator := ""
 	translator += "@SP\n"
-	translator += "@A=M-1\n"
-	translator += "@M=!M\n"
+	translator += "A=M-1\n"
+	translator += "M=!M\n"
 	return ASMType(translator)
 }
 
@@ -239,6 +254,7 @@ func (c *CodeWriter) eq() ASMType {
 	translator += "@SP\n"
 	translator += "AM=M-1\n"
 	translator += "D=M\n"
+	translator += "@SP\n"
 	translator += "AM=M-1\n"
 	translator += "D=M-D\n"
 	translator += "M=-1\n"                               // Assume they are equal and store true (-1) at the top of the stack
@@ -260,6 +276,7 @@ func (c *CodeWriter) gt() ASMType {
 	translator += "@SP\n"
 	translator += "AM=M-1\n"
 	translator += "D=M\n"
+	translator += "@SP\n"
 	translator += "AM=M-1\n"
 	translator += "D=M-D\n"
 	translator += "M=-1\n" // Assume greater and store true (-1) at the top of the stack
@@ -281,11 +298,12 @@ func (c *CodeWriter) lt() ASMType {
 	translator += "@SP\n"
 	translator += "AM=M-1\n"
 	translator += "D=M\n"
+	translator += "@SP\n"
 	translator += "AM=M-1\n"
 	translator += "D=M-D\n"
 	translator += "M=-1\n" // Assume lesser and store true (-1) at the top of the stack
 	translator += fmt.Sprintf("@LT_END%d", label) + "\n"
-	translator += "D;JGT\n"
+	translator += "D;JLT\n"
 	translator += "@SP\n" // If not less, store false (0) at the top of the stack
 	translator += "A=M\n"
 	translator += "M=0\n"
@@ -373,7 +391,7 @@ func (c *CodeWriter) popThat(index int) ASMType {
 
 func (c *CodeWriter) pushStatic(index int) ASMType {
 	translator := ""
-	translator += fmt.Sprintf("@STATIC_%d", index) + "\n"
+	translator += fmt.Sprintf("@%s.%d", c.fileName, index) + "\n"
 	translator += "D=M\n"
 	translator += "@SP\n"
 	translator += "A=M\n"
@@ -385,16 +403,11 @@ func (c *CodeWriter) pushStatic(index int) ASMType {
 
 func (c *CodeWriter) popStatic(index int) ASMType {
 	translator := ""
-	translator += fmt.Sprintf("@STATIC_%d", index) + "\n"
-	translator += "D=A\n"
-	translator += "@R13\n"
-	translator += "@M=D\n" // M[index] = STATIC_index
 	translator += "@SP\n"
 	translator += "AM=M-1\n"
 	translator += "D=M\n"
-	translator += "@R13"
-	translator += "A=M\n" // Set the A-register to the target address
-	translator += "M=D\n" // Store value from the stack into the target local variable
+	translator += fmt.Sprintf("@%s.%d", c.fileName, index) + "\n"
+	translator += "M=D\n" // Store value from the stack into the static variable
 	return ASMType(translator)
 }
 
@@ -530,6 +543,15 @@ func (c *CodeWriter) popPointer(index int) ASMType {
 }
 
 func (c *CodeWriter) writerPushPop(command string, segment string, index int) (ASMType, error) {
+	if index < 0 || index > 32767 {
+		return "", fmt.Errorf("index %d out of range 0..32767", index)
+	} else if segment == "pointer" && index > 1 {
+		return "", fmt.Errorf("pointer index %d out of range 0..1", index)
+	} else if segment == "temp" && index > 7 {
+		return "", fmt.Errorf("temp index %d out of range 0..7", index)
+	} else if command == "pop" && segment == "constant" {
+		return "", fmt.Errorf("cannot pop to the constant segment")
+	}
 	if command == "push" {
 		switch segment {
 		case "pointer":
@@ -567,7 +589,7 @@ func (c *CodeWriter) writerPushPop(command string, segment string, index int) (A
 			return c.popTemp(index), nil
 		}
 	}
-	return "", fmt.Errorf("The command not implemented yet")
+	return "", fmt.Errorf("unknown segment %q", segment)
 
 }
 func (c *CodeWriter) writeArithmetic(command string) (ASMType, error) {
@@ -591,52 +613,80 @@ func (c *CodeWriter) writeArithmetic(command string) (ASMType, error) {
 	case "or":
 		return c.or(), nil
 	}
-	return "", fmt.Errorf("The command not implemented yet")
+	return "", fmt.Errorf("unknown command %q", command)
+}
+
+// translate turns the current command into assembly. Program flow and
+// function commands belong to the projects/08 translator.
+func (c *CodeWriter) translate() (ASMType, error) {
+	arguments := c.parser.arguments()
+	switch c.parser.commandType(arguments[0]) {
+	case C_ARITHMETIC:
+		if len(arguments) != 1 {
+			return "", fmt.Errorf("%s takes 0 argument(s), got %d", arguments[0], len(arguments)-1)
+		}
+		return c.writeArithmetic(arguments[0])
+	case C_PUSH, C_POP:
+		if len(arguments) != 3 {
+			return "", fmt.Errorf("%s takes 2 argument(s), got %d", arguments[0], len(arguments)-1)
+		}
+		index, err := strconv.Atoi(arguments[2])
+		if err != nil {
+			return "", fmt.Errorf("index %q is not a number", arguments[2])
+		}
+		return c.writerPushPop(arguments[0], arguments[1], index)
+	case C_UNKNOW:
+		return "", fmt.Errorf("unknown command %q", arguments[0])
+	}
+	return "", fmt.Errorf("%s is not supported by the projects/07 translator", arguments[0])
 }
 
-func (c *CodeWriter) genCode() {
+// genCode translates every command of the file. Malformed commands are
+// reported with their file and line and translation goes on, so one run
+// reports them all.
+func (c *CodeWriter) genCode() []error {
+	errs := []error{}
 	c.parser.reset()
 	for c.parser.hasMoreCommands() {
-		translateCode := ""
 		c.parser.advance()
-		cmType := c.parser.commandType(string(c.parser.arg1))
-		if cmType == C_POP || cmType == C_PUSH {
-			splitText := strings.Split(c.parser.currentCommand, " ")
-			index, _ := strconv.Atoi(splitText[2])
-			code, err := c.writerPushPop(splitText[0], splitText[1], index)
-			if err != nil {
-				continue
-			}
-			translateCode = string(code)
-		} else {
-			code, err := c.writeArithmetic(string(c.parser.arg1))
-			if err != nil {
-				continue
-			}
-			translateCode = string(code)
+		if c.parser.currentCommand == "" {
+			continue
+		}
+		code, err := c.translate()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %v", c.parser.fileName, c.parser.currentRow, err))
+			continue
 		}
-		c.writer.writer.WriteString(translateCode)
+		c.writer.writer.WriteString(string(code))
 	}
+	return errs
 }
 
 func main() {
 	if len(os.Args) != 2 {
-		return
+		fmt.Fprintln(os.Stderr, "Usage: translator <file.vm>")
+		os.Exit(2)
 	}
 
-	// Frist pass: Clear comment
 	parser, err := NewParser(os.Args[1])
 	if err != nil {
-		fmt.Println("Error:", err)
-		return
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
 	}
-	outputFile := strings.Replace(os.Args[1], ".vm", ".asm", 1)
-	writer, err2 := createFile(outputFile)
-	if err2 != nil {
-		fmt.Println("Error:", err)
-		return
+	writer := newWriter()
+	codeWriter := NewCodeWriter(parser, writer)
+	codeWriter.setFileName(os.Args[1])
+	errs := codeWriter.genCode()
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if len(errs) > 0 {
+		os.Exit(1)
 	}
 
-	codeWriter := NewCodeWriter(parser, writer)
-	codeWriter.genCode()
+	outputFile := strings.TrimSuffix(os.Args[1], filepath.Ext(os.Args[1])) + ".asm"
+	if err := writer.save(outputFile); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
 }
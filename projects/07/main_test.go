@@ -0,0 +1,77 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// translateFile runs the translator on a .vm file and returns the assembly
+// and the errors it reported.
+func translateFile(t *testing.T, fileName string) (string, []error) {
+	t.Helper()
+	parser, err := NewParser(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := newWriter()
+	codeWriter := NewCodeWriter(parser, writer)
+	codeWriter.setFileName(fileName)
+	errs := codeWriter.genCode()
+	return writer.writer.String(), errs
+}
+
+var staticSymbol = regexp.MustCompile(`(?m)^@(\w+)\.(\d+)$`)
+
+// staticNames returns the static variables the assembly refers to.
+func staticNames(asm string) map[string]bool {
+	names := map[string]bool{}
+	for _, match := range staticSymbol.FindAllStringSubmatch(asm, -1) {
+		names[match[1]+"."+match[2]] = true
+	}
+	return names
+}
+
+func TestStaticNames(t *testing.T) {
+	asm, errs := translateFile(t, filepath.Join("MemoryAccess", "StaticTest", "StaticTest.vm"))
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	want := map[string]bool{"StaticTest.3": true, "StaticTest.1": true, "StaticTest.8": true}
+	got := staticNames(asm)
+	if len(got) != len(want) {
+		t.Errorf("static variables %v, want %v", got, want)
+	}
+	for name := range want {
+		if !got[name] {
+			t.Errorf("missing static variable %s in %v", name, got)
+		}
+	}
+}
+
+// The classes of the projects/08 StaticsTest both use static 0 and 1; each
+// file must get its own variables. Their function commands belong to the
+// projects/08 translator and are reported as unsupported here.
+func TestStaticsIsolation(t *testing.T) {
+	dir := filepath.Join("..", "08", "FunctionCalls", "StaticsTest")
+	seen := map[string]string{}
+	for _, class := range []string{"Class1", "Class2"} {
+		asm, errs := translateFile(t, filepath.Join(dir, class+".vm"))
+		for _, err := range errs {
+			if !strings.Contains(err.Error(), "not supported") {
+				t.Errorf("%s: %v", class, err)
+			}
+		}
+		names := staticNames(asm)
+		if len(names) != 2 || !names[class+".0"] || !names[class+".1"] {
+			t.Errorf("%s uses static variables %v, want %s.0 and %s.1", class, names, class, class)
+		}
+		for name := range names {
+			if other, exist := seen[name]; exist {
+				t.Errorf("%s and %s share the static variable %s", other, class, name)
+			}
+			seen[name] = class
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Package vmtranslator translates the Hack VM language into Hack assembly.
+// Parse turns VM code into typed commands and Translate writes them out
+// as assembly, so tools other than the command-line translator can read,
+// build or rewrite VM programs.
+package vmtranslator
+
+import (
+	"fmt"
+
+	"github.com/MinhNHHH/nand2tetris/projects/06/hackisa"
+)
+
+type CommandKind int
+
+const (
+	Arithmetic CommandKind = iota
+	Push
+	Pop
+	Label
+	Goto
+	IfGoto
+	Function
+	Call
+	Return
+)
+
+var kindNames = [...]string{"arithmetic", "push", "pop", "label", "goto", "if-goto", "function", "call", "return"}
+
+func (k CommandKind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return fmt.Sprintf("CommandKind(%d)", int(k))
+	}
+	return kindNames[k]
+}
+
+// Operator is the operation of an arithmetic or logical command.
+type Operator int
+
+const (
+	Add Operator = iota
+	Sub
+	Neg
+	Eq
+	Gt
+	Lt
+	And
+	Or
+	Not
+)
+
+var operatorNames = [...]string{"add", "sub", "neg", "eq", "gt", "lt", "and", "or", "not"}
+
+func (o Operator) String() string {
+	if o < 0 || int(o) >= len(operatorNames) {
+		return fmt.Sprintf("Operator(%d)", int(o))
+	}
+	return operatorNames[o]
+}
+
+// ParseOperator looks up an arithmetic command by its VM name.
+func ParseOperator(name string) (Operator, bool) {
+	for operator, operatorName := range operatorNames {
+		if operatorName == name {
+			return Operator(operator), true
+		}
+	}
+	return 0, false
+}
+
+// Segment is one of the eight virtual memory segments.
+type Segment int
+
+const (
+	Constant Segment = iota
+	Local
+	Argument
+	This
+	That
+	Pointer
+	Temp
+	Static
+)
+
+var segmentNames = [...]string{"constant", "local", "argument", "this", "that", "pointer", "temp", "static"}
+
+func (s Segment) String() string {
+	if s < 0 || int(s) >= len(segmentNames) {
+		return fmt.Sprintf("Segment(%d)", int(s))
+	}
+	return segmentNames[s]
+}
+
+// ParseSegment looks up a segment by its VM name.
+func ParseSegment(name string) (Segment, bool) {
+	for segment, segmentName := range segmentNames {
+		if segmentName == name {
+			return Segment(segment), true
+		}
+	}
+	return 0, false
+}
+
+// Command is one VM command. Which fields are used depends on Kind:
+// Operator for Arithmetic; Segment and Index for Push and Pop; Name for
+// Label, Goto, IfGoto, Function and Call; Count for the local variables of
+// a Function and the arguments of a Call.
+//
+// File and Line say where the command came from. File also scopes static
+// variables, which are named after its base name.
+type Command struct {
+	Kind     CommandKind
+	Operator Operator
+	Segment  Segment
+	Index    int
+	Name     string
+	Count    int
+
+	File string
+	Line int
+}
+
+// String formats the command as a line of VM code.
+func (c Command) String() string {
+	switch c.Kind {
+	case Arithmetic:
+		return c.Operator.String()
+	case Push, Pop:
+		return fmt.Sprintf("%s %s %d", c.Kind, c.Segment, c.Index)
+	case Label, Goto, IfGoto:
+		return fmt.Sprintf("%s %s", c.Kind, c.Name)
+	case Function, Call:
+		return fmt.Sprintf("%s %s %d", c.Kind, c.Name, c.Count)
+	}
+	return c.Kind.String()
+}
+
+// check reports commands the Hack platform cannot run, such as pop
+// constant or temp 8.
+func (c Command) check() error {
+	switch c.Kind {
+	case Arithmetic:
+		if c.Operator < 0 || int(c.Operator) >= len(operatorNames) {
+			return fmt.Errorf("unknown operator %v", c.Operator)
+		}
+	case Push, Pop:
+		switch {
+		case c.Segment < 0 || int(c.Segment) >= len(segmentNames):
+			return fmt.Errorf("unknown segment %v", c.Segment)
+		case c.Index < 0 || c.Index > 32767:
+			return fmt.Errorf("index %d out of range 0..32767", c.Index)
+		case c.Segment == Pointer && c.Index > 1:
+			return fmt.Errorf("pointer index %d out of range 0..1", c.Index)
+		case c.Segment == Temp && c.Index > 7:
+			return fmt.Errorf("temp index %d out of range 0..7", c.Index)
+		case c.Kind == Pop && c.Segment == Constant:
+			return fmt.Errorf("cannot pop to the constant segment")
+		}
+	case Label, Goto, IfGoto, Function, Call:
+		if c.Name == "" {
+			return fmt.Errorf("missing name")
+		}
+		if index := hackisa.BadSymbolChar(c.Name); index != -1 {
+			return fmt.Errorf("illegal character %q in name %q", c.Name[index], c.Name)
+		}
+		if c.Count < 0 {
+			return fmt.Errorf("count %d is negative", c.Count)
+		}
+	case Return:
+	default:
+		return fmt.Errorf("unknown command kind %v", c.Kind)
+	}
+	return nil
+}
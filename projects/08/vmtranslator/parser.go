@@ -0,0 +1,152 @@
+package vmtranslator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Error is a malformed VM command.
+type Error struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+}
+
+// ErrorList holds every malformed command found in a run, so all of them
+// can be reported at once.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	messages := make([]string, len(l))
+	for i, err := range l {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// argumentCounts is how many arguments each kind of command takes.
+var argumentCounts = map[string]int{
+	"push":     2,
+	"pop":      2,
+	"label":    1,
+	"goto":     1,
+	"if-goto":  1,
+	"function": 2,
+	"call":     2,
+	"return":   0,
+}
+
+var commandKinds = map[string]CommandKind{
+	"push":     Push,
+	"pop":      Pop,
+	"label":    Label,
+	"goto":     Goto,
+	"if-goto":  IfGoto,
+	"function": Function,
+	"call":     Call,
+	"return":   Return,
+}
+
+// Parse reads VM code. Malformed commands are returned as an ErrorList
+// together with the commands that did parse.
+func Parse(r io.Reader) ([]Command, error) {
+	return ParseFile(r, "")
+}
+
+// ParseFile is Parse for code read from fileName, which is recorded in
+// every command and error. Static variables are scoped to the file.
+func ParseFile(r io.Reader, fileName string) ([]Command, error) {
+	commands := []Command{}
+	errs := ErrorList{}
+	scanner := bufio.NewScanner(r)
+	for row := 1; scanner.Scan(); row++ {
+		line := scanner.Text()
+		if index := strings.Index(line, "//"); index != -1 {
+			line = line[:index]
+		}
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			continue
+		}
+		command, err := parseCommand(words)
+		if err == nil {
+			err = command.check()
+		}
+		if err != nil {
+			errs = append(errs, &Error{File: fileName, Line: row, Message: err.Error()})
+			continue
+		}
+		command.File, command.Line = fileName, row
+		commands = append(commands, command)
+	}
+	if err := scanner.Err(); err != nil {
+		return commands, err
+	}
+	if len(errs) > 0 {
+		return commands, errs
+	}
+	return commands, nil
+}
+
+func parseCommand(words []string) (Command, error) {
+	if operator, ok := ParseOperator(words[0]); ok {
+		if len(words) != 1 {
+			return Command{}, fmt.Errorf("%s takes 0 argument(s), got %d", words[0], len(words)-1)
+		}
+		return Command{Kind: Arithmetic, Operator: operator}, nil
+	}
+	kind, ok := commandKinds[words[0]]
+	if !ok {
+		return Command{}, fmt.Errorf("unknown command %q", words[0])
+	}
+	if len(words)-1 != argumentCounts[words[0]] {
+		return Command{}, fmt.Errorf("%s takes %d argument(s), got %d", words[0], argumentCounts[words[0]], len(words)-1)
+	}
+
+	command := Command{Kind: kind}
+	switch kind {
+	case Push, Pop:
+		segment, ok := ParseSegment(words[1])
+		if !ok {
+			return Command{}, fmt.Errorf("unknown segment %q", words[1])
+		}
+		index, err := parseNumber(words[2], "index")
+		if err != nil {
+			return Command{}, err
+		}
+		command.Segment, command.Index = segment, index
+	case Label, Goto, IfGoto:
+		command.Name = words[1]
+	case Function, Call:
+		what := "argument count"
+		if kind == Function {
+			what = "local variable count"
+		}
+		count, err := parseNumber(words[2], what)
+		if err != nil {
+			return Command{}, err
+		}
+		command.Name, command.Count = words[1], count
+	}
+	return command, nil
+}
+
+// parseNumber reads a non-negative numeric argument such as a segment
+// index or an argument count.
+func parseNumber(text string, what string) (int, error) {
+	number, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("%s %q is not a number", what, text)
+	}
+	if number < 0 {
+		return 0, fmt.Errorf("%s %d is negative", what, number)
+	}
+	return number, nil
+}
@@ -0,0 +1,52 @@
+package vmtranslator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseNames(t *testing.T) {
+	source := `function Main.main 0
+label WHILE_EXP0
+label a@b
+goto x-y
+if-goto 1loop
+call Foo.bar$baz:1 0
+call Sys.init 0
+function two words 0
+`
+	commands, err := ParseFile(strings.NewReader(source), "Main.vm")
+	errs, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("ParseFile error = %v, want an ErrorList", err)
+	}
+	want := []string{
+		`Main.vm:3: illegal character '@' in name "a@b"`,
+		`Main.vm:4: illegal character '-' in name "x-y"`,
+		`Main.vm:5: illegal character '1' in name "1loop"`,
+		`Main.vm:8: function takes 2 argument(s), got 3`,
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got errors\n%v\nwant\n%s", errs, strings.Join(want, "\n"))
+	}
+	for i, err := range errs {
+		if err.Error() != want[i] {
+			t.Errorf("error %d = %q, want %q", i, err.Error(), want[i])
+		}
+	}
+	if len(commands) != 4 {
+		t.Errorf("parsed %d commands, want the 4 valid ones", len(commands))
+	}
+}
+
+// Commands built in code go through the same check when translated.
+func TestTranslateBadName(t *testing.T) {
+	var output strings.Builder
+	err := Translate([]Command{{Kind: Goto, Name: "a b", File: "Main.vm", Line: 7}}, &output, Options{})
+	if err == nil || err.Error() != `Main.vm:7: illegal character ' ' in name "a b"` {
+		t.Errorf("Translate error = %v", err)
+	}
+	if output.Len() != 0 {
+		t.Errorf("Translate wrote %q for a bad command", output.String())
+	}
+}
@@ -0,0 +1,246 @@
+package vmtranslator
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Options controls a single call to Translate.
+type Options struct {
+	// Bootstrap starts the program with SP = 256 and a call to Sys.init,
+	// as a whole program needs. Single files tested on their own set up
+	// the stack in their test script instead.
+	Bootstrap bool
+	// FileName scopes the static variables of commands without a File.
+	FileName string
+}
+
+// Translate writes the Hack assembly for commands to w. Commands are
+// checked first, so nothing is written if any is malformed.
+func Translate(commands []Command, w io.Writer, options Options) error {
+	errs := ErrorList{}
+	for _, command := range commands {
+		if err := command.check(); err != nil {
+			errs = append(errs, &Error{File: command.File, Line: command.Line, Message: err.Error()})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	c := &codeWriter{out: bufio.NewWriter(w)}
+	if options.Bootstrap {
+		c.writeInit()
+	}
+	c.setFileName(options.FileName)
+	file := options.FileName
+	for _, command := range commands {
+		if command.File == "" {
+			command.File = options.FileName
+		}
+		if command.File != file {
+			c.setFileName(command.File)
+			file = command.File
+		}
+		c.write(command)
+	}
+	return c.out.Flush()
+}
+
+// segmentBases are the pointers holding the base address of the
+// segments that live on the heap or the stack.
+var segmentBases = map[Segment]string{
+	Local:    "LCL",
+	Argument: "ARG",
+	This:     "THIS",
+	That:     "THAT",
+}
+
+type codeWriter struct {
+	out *bufio.Writer
+	// fileName is the base name of the file being translated; static
+	// variables are emitted as fileName.index so each file has its own.
+	fileName string
+	// functionName is the function being translated; labels inside it
+	// are emitted as functionName$label.
+	functionName string
+	// label numbers the labels of comparisons, call numbers return-address
+	// labels, so each use gets its own.
+	label int
+	call  int
+}
+
+func (c *codeWriter) emit(lines ...string) {
+	for _, line := range lines {
+		c.out.WriteString(line)
+		c.out.WriteByte('\n')
+	}
+}
+
+// setFileName starts translating commands from a new .vm file. Commands
+// with no file at all share the static variables Static.index.
+func (c *codeWriter) setFileName(filePath string) {
+	c.fileName = "Static"
+	if filePath != "" {
+		c.fileName = strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	}
+	c.functionName = ""
+}
+
+func (c *codeWriter) write(command Command) {
+	switch command.Kind {
+	case Arithmetic:
+		c.writeArithmetic(command.Operator)
+	case Push:
+		c.writePush(command.Segment, command.Index)
+	case Pop:
+		c.writePop(command.Segment, command.Index)
+	case Label:
+		c.emit(fmt.Sprintf("(%s)", c.scopedLabel(command.Name)))
+	case Goto:
+		c.emit("@"+c.scopedLabel(command.Name), "0;JMP")
+	case IfGoto:
+		c.popD()
+		c.emit("@"+c.scopedLabel(command.Name), "D;JNE") // Jumps unless the condition is false (0)
+	case Function:
+		c.writeFunction(command.Name, command.Count)
+	case Call:
+		c.writeCall(command.Name, command.Count)
+	case Return:
+		c.writeReturn()
+	}
+}
+
+// pushD pushes the D register onto the stack.
+func (c *codeWriter) pushD() {
+	c.emit("@SP", "A=M", "M=D", "@SP", "M=M+1")
+}
+
+// popD pops the top of the stack into the D register.
+func (c *codeWriter) popD() {
+	c.emit("@SP", "AM=M-1", "D=M")
+}
+
+func (c *codeWriter) writeArithmetic(operator Operator) {
+	switch operator {
+	case Neg:
+		c.emit("@SP", "A=M-1", "M=-M")
+	case Not:
+		c.emit("@SP", "A=M-1", "M=!M")
+	case Add, Sub, And, Or:
+		c.popD()
+		c.emit("@SP", "A=M-1", map[Operator]string{Add: "M=D+M", Sub: "M=M-D", And: "M=D&M", Or: "M=D|M"}[operator])
+	case Eq, Gt, Lt:
+		// Assume the comparison holds and store true (-1), then overwrite it
+		// with false (0) unless the jump skips that.
+		end := fmt.Sprintf("%s_END%d", strings.ToUpper(operator.String()), c.label)
+		c.label += 1
+		c.popD()
+		c.emit("@SP", "A=M-1", "D=M-D", "M=-1")
+		c.emit("@"+end, "D;J"+strings.ToUpper(operator.String()))
+		c.emit("@SP", "A=M-1", "M=0")
+		c.emit(fmt.Sprintf("(%s)", end))
+	}
+}
+
+// address returns the RAM address of a segment entry that has a fixed
+// place, or "" for the segments addressed through a base pointer.
+func (c *codeWriter) address(segment Segment, index int) string {
+	switch segment {
+	case Temp:
+		return fmt.Sprintf("@R%d", 5+index)
+	case Pointer:
+		return []string{"@THIS", "@THAT"}[index]
+	case Static:
+		return fmt.Sprintf("@%s.%d", c.fileName, index)
+	}
+	return ""
+}
+
+func (c *codeWriter) writePush(segment Segment, index int) {
+	if segment == Constant {
+		c.emit(fmt.Sprintf("@%d", index), "D=A")
+	} else if base, exist := segmentBases[segment]; exist {
+		c.emit("@"+base, "D=M", fmt.Sprintf("@%d", index), "A=D+A", "D=M")
+	} else {
+		c.emit(c.address(segment, index), "D=M")
+	}
+	c.pushD()
+}
+
+func (c *codeWriter) writePop(segment Segment, index int) {
+	base, exist := segmentBases[segment]
+	if !exist {
+		c.popD()
+		c.emit(c.address(segment, index), "M=D")
+		return
+	}
+	// Keep the target address in R13 while the value is popped into D.
+	c.emit("@"+base, "D=M", fmt.Sprintf("@%d", index), "D=D+A", "@R13", "M=D")
+	c.popD()
+	c.emit("@R13", "A=M", "M=D")
+}
+
+// scopedLabel names a VM label inside the current function, so equal
+// labels in different functions do not clash.
+func (c *codeWriter) scopedLabel(label string) string {
+	if c.functionName == "" {
+		return label
+	}
+	return c.functionName + "$" + label
+}
+
+// writeInit emits the bootstrap code: SP = 256, call Sys.init.
+func (c *codeWriter) writeInit() {
+	c.emit("@256", "D=A", "@SP", "M=D")
+	c.writeCall("Sys.init", 0)
+}
+
+// writeFunction declares the function's entry label and clears its nVars
+// local variables by pushing zeros.
+func (c *codeWriter) writeFunction(functionName string, nVars int) {
+	c.functionName = functionName
+	c.emit(fmt.Sprintf("(%s)", functionName))
+	for i := 0; i < nVars; i++ {
+		c.writePush(Constant, 0)
+	}
+}
+
+// writeCall pushes the return address and the caller's LCL, ARG, THIS and
+// THAT, repositions ARG and LCL for the callee and jumps to it.
+func (c *codeWriter) writeCall(functionName string, nArgs int) {
+	caller := c.functionName
+	if caller == "" {
+		caller = "Bootstrap"
+	}
+	returnLabel := fmt.Sprintf("%s$ret.%d", caller, c.call)
+	c.call += 1
+
+	c.emit("@"+returnLabel, "D=A")
+	c.pushD()
+	for _, pointer := range []string{"LCL", "ARG", "THIS", "THAT"} {
+		c.emit("@"+pointer, "D=M")
+		c.pushD()
+	}
+	c.emit("@SP", "D=M", fmt.Sprintf("@%d", 5+nArgs), "D=D-A", "@ARG", "M=D") // ARG = SP - 5 - nArgs
+	c.emit("@SP", "D=M", "@LCL", "M=D")                                       // LCL = SP
+	c.emit("@"+functionName, "0;JMP")
+	c.emit(fmt.Sprintf("(%s)", returnLabel))
+}
+
+// writeReturn puts the return value where the caller's ARG 0 was, restores
+// the caller's frame and jumps back to the return address.
+func (c *codeWriter) writeReturn() {
+	c.emit("@LCL", "D=M", "@R13", "M=D")        // R13 = frame = LCL
+	c.emit("@5", "A=D-A", "D=M", "@R14", "M=D") // R14 = return address = *(frame - 5)
+	c.popD()
+	c.emit("@ARG", "A=M", "M=D")          // *ARG = pop()
+	c.emit("@ARG", "D=M+1", "@SP", "M=D") // SP = ARG + 1
+	for _, pointer := range []string{"THAT", "THIS", "ARG", "LCL"} {
+		c.emit("@R13", "AM=M-1", "D=M", "@"+pointer, "M=D") // pointer = *(--frame)
+	}
+	c.emit("@R14", "A=M", "0;JMP")
+}
@@ -0,0 +1,70 @@
+package vmtranslator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/MinhNHHH/nand2tetris/projects/06/tstscript"
+)
+
+// translateDir translates the .vm files of a course test directory into a
+// copy of it, the way the command-line translator does, and returns the
+// copy's path.
+func translateDir(t *testing.T, dir string) string {
+	t.Helper()
+	to := filepath.Join(t.TempDir(), filepath.Base(dir))
+	if err := tstscript.CopyDir(dir, to); err != nil {
+		t.Fatal(err)
+	}
+	vmFiles, err := filepath.Glob(filepath.Join(to, "*.vm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	commands := []Command{}
+	for _, path := range vmFiles {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fileCommands, err := ParseFile(bytes.NewReader(content), filepath.Base(path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		commands = append(commands, fileCommands...)
+	}
+
+	var asm bytes.Buffer
+	if err := Translate(commands, &asm, Options{Bootstrap: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(to, filepath.Base(dir)+".asm"), asm.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return to
+}
+
+// Class1 and Class2 both use static 0 and 1. The test script checks the
+// values each class computes from its own pair, which only come out right
+// if the two files do not share static variables.
+func TestStaticsIsolation(t *testing.T) {
+	dir := translateDir(t, filepath.Join("..", "FunctionCalls", "StaticsTest"))
+	asm, err := os.ReadFile(filepath.Join(dir, "StaticsTest.asm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, symbol := range []string{"@Class1.0", "@Class1.1", "@Class2.0", "@Class2.1"} {
+		if !bytes.Contains(asm, []byte(symbol+"\n")) {
+			t.Errorf("StaticsTest.asm does not use %s", symbol)
+		}
+	}
+
+	result, err := tstscript.RunFile(filepath.Join(dir, "StaticsTest.tst"), tstscript.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Mismatch != nil {
+		t.Error(result.Mismatch)
+	}
+}
@@ -0,0 +1,162 @@
+// Command vmtest runs the course tests for the VM translators: for every
+// directory under projects/07 and projects/08 with a .tst script and .vm
+// files it translates the .vm code, assembles and runs it on the Go CPU
+// emulator and compares the output to the .cmp file.
+//
+// Run it from the repository root:
+//
+//	go run ./projects/vmtest [dir ...]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/MinhNHHH/nand2tetris/projects/06/tstscript"
+)
+
+// test is one .tst script together with the translator project it tests.
+type test struct {
+	name    string
+	dir     string
+	script  string
+	project string
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: vmtest [dir ...] (default: projects/07 projects/08)")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	roots := flag.Args()
+	if len(roots) == 0 {
+		roots = []string{filepath.Join("projects", "07"), filepath.Join("projects", "08")}
+	}
+
+	tests, err := findTests(roots)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	workDir, err := os.MkdirTemp("", "vmtest")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	defer os.RemoveAll(workDir)
+
+	translators := map[string]string{}
+	failed := 0
+	for _, t := range tests {
+		if err := runTest(t, workDir, translators); err != nil {
+			failed++
+			// Report paths inside the copy as paths in the tree.
+			message := strings.ReplaceAll(err.Error(), filepath.Join(workDir, "tests")+string(filepath.Separator), "")
+			fmt.Printf("FAIL %s\n     %s\n", t.name, strings.ReplaceAll(message, "\n", "\n     "))
+			continue
+		}
+		fmt.Printf("PASS %s\n", t.name)
+	}
+	fmt.Printf("%d passed, %d failed\n", len(tests)-failed, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// findTests looks for .tst scripts next to .vm files. The VME scripts are
+// for the VM emulator and are skipped.
+func findTests(roots []string) ([]test, error) {
+	tests := []test{}
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || filepath.Ext(path) != ".tst" || strings.HasSuffix(path, "VME.tst") {
+				return nil
+			}
+			dir := filepath.Dir(path)
+			if vmFiles, _ := filepath.Glob(filepath.Join(dir, "*.vm")); len(vmFiles) == 0 {
+				return nil
+			}
+			project, err := findProject(dir)
+			if err != nil {
+				return err
+			}
+			tests = append(tests, test{name: dir, dir: dir, script: filepath.Base(path), project: project})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tests, nil
+}
+
+// findProject returns the nearest directory above dir holding the
+// translator's main.go.
+func findProject(dir string) (string, error) {
+	for current := dir; ; current = filepath.Dir(current) {
+		if _, err := os.Stat(filepath.Join(current, "main.go")); err == nil {
+			return current, nil
+		}
+		if parent := filepath.Dir(current); parent == current {
+			return "", fmt.Errorf("no translator main.go above %s", dir)
+		}
+	}
+}
+
+// runTest copies the test directory to workDir so the tree stays clean,
+// translates it and runs the script.
+func runTest(t test, workDir string, translators map[string]string) error {
+	translator, err := buildTranslator(t.project, workDir, translators)
+	if err != nil {
+		return err
+	}
+	// Keep the directory name: a translated directory is named after it.
+	dir := filepath.Join(workDir, "tests", t.dir)
+	if err := tstscript.CopyDir(t.dir, dir); err != nil {
+		return err
+	}
+
+	// A lone .vm file named after the directory is translated on its own;
+	// anything else is translated as a whole program.
+	target := dir
+	vmFiles, _ := filepath.Glob(filepath.Join(dir, "*.vm"))
+	if len(vmFiles) == 1 && strings.TrimSuffix(filepath.Base(vmFiles[0]), ".vm") == filepath.Base(t.dir) {
+		target = vmFiles[0]
+	}
+	if output, err := exec.Command(translator, target).CombinedOutput(); err != nil {
+		return fmt.Errorf("translator failed: %v\n%s", err, strings.TrimSpace(string(output)))
+	}
+
+	result, err := tstscript.RunFile(filepath.Join(dir, t.script), tstscript.Options{})
+	if err != nil {
+		return err
+	}
+	if result.Mismatch != nil {
+		return result.Mismatch
+	}
+	return nil
+}
+
+// buildTranslator compiles the translator in project once per run.
+func buildTranslator(project string, workDir string, translators map[string]string) (string, error) {
+	if binary, exist := translators[project]; exist {
+		return binary, nil
+	}
+	binary := filepath.Join(workDir, fmt.Sprintf("translator%d", len(translators)))
+	command := exec.Command("go", "build", "-o", binary, ".")
+	command.Dir = project
+	if output, err := command.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("building %s: %v\n%s", project, err, strings.TrimSpace(string(output)))
+	}
+	translators[project] = binary
+	return binary, nil
+}